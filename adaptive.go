@@ -0,0 +1,210 @@
+package gocache
+
+import (
+	"log"
+	"math"
+	"os"
+	"runtime/debug"
+	"runtime/metrics"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	adaptiveHysteresis = 0.1     // the fraction under the target at which capacities start growing again
+	adaptiveGrowth     = 0.1     // the fraction by which capacities grow per adjustment
+	minAdaptiveGrowth  = 1 << 20 // the minimum growth of a capacity per adjustment
+)
+
+// An Adaptive keeps the memory of the process under a target fraction of its memory limit
+// by shrinking and growing the capacities of its groups.
+// The memory limit is the lower of GOMEMLIMIT and the cgroup memory limit.
+//
+// When the process uses more than the target, the excess over the middle of the hysteresis band under the target
+// is taken from the groups in proportion to their sizes. The memory of the evicted entries is only given back by
+// a garbage collection, so the capacities are not shrunk again until one has completed.
+// When it uses comfortably less, the capacities grow back towards the ones the groups were created with.
+// A group created with no limit grows back until its entries use less than half of its capacity,
+// and then has no limit again.
+type Adaptive struct {
+	mu     sync.Mutex
+	target float64          // the target fraction of the memory limit
+	groups map[*Group]int64 // maps groups to the capacities they were created with
+	done   chan struct{}
+
+	// The number of completed GC cycles from which the capacities can shrink again.
+	nextShrink uint64
+
+	memoryLimit func() int64  // returns the memory limit of the process; 0 means no limit
+	memoryUsed  func() int64  // returns the memory used by the process
+	gcCycles    func() uint64 // returns the number of completed GC cycles
+}
+
+// NewAdaptive creates an Adaptive keeping the process under target (e.g. 0.8) times its memory limit.
+func NewAdaptive(target float64) *Adaptive {
+	return &Adaptive{
+		target:      target,
+		groups:      make(map[*Group]int64),
+		memoryLimit: memoryLimit,
+		memoryUsed:  memoryUsed,
+		gcCycles:    gcCycles,
+	}
+}
+
+// WithAdaptive makes the capacity of the group adjusted by a.
+func WithAdaptive(a *Adaptive) GroupOption {
+	return func(g *Group) {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		a.groups[g] = g.mainCache.capacity
+		g.adaptive = a
+	}
+}
+
+// Start adjusts the capacities every interval until Stop is called.
+func (a *Adaptive) Start(interval time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.done != nil {
+		return
+	}
+	a.done = make(chan struct{})
+	go func(done chan struct{}) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				a.Adjust()
+			case <-done:
+				return
+			}
+		}
+	}(a.done)
+}
+
+// Stop stops the periodic adjustments.
+func (a *Adaptive) Stop() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.done != nil {
+		close(a.done)
+		a.done = nil
+	}
+}
+
+// Adjust adjusts the capacities of the groups once according to the current memory usage.
+func (a *Adaptive) Adjust() {
+	limit := a.memoryLimit()
+	if limit <= 0 {
+		return
+	}
+	target := int64(float64(limit) * a.target)
+	used := a.memoryUsed()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	switch {
+	case used > target:
+		cycles := a.gcCycles()
+		if cycles < a.nextShrink {
+			// The memory freed by the last shrink has not been collected yet.
+			return
+		}
+		sizes := make(map[*Group]int64, len(a.groups))
+		var total int64
+		for g := range a.groups {
+			sizes[g] = g.mainCache.size()
+			total += sizes[g]
+		}
+		if total == 0 {
+			return
+		}
+		// Aim at the middle of the hysteresis band, so that neither a shrink nor a growth follows right away.
+		excess := used - int64(float64(target)*(1-adaptiveHysteresis/2))
+		for g, size := range sizes {
+			if size == 0 {
+				// Shrinking an empty group frees nothing.
+				continue
+			}
+			// A capacity of 0 means no limit, so 1 is the smallest one.
+			capacity := max(size-int64(float64(excess)*float64(size)/float64(total)), 1)
+			g.mainCache.setCapacity(capacity)
+		}
+		a.nextShrink = cycles + 1
+		log.Printf("[gocache] adaptive: shrank %d groups by %d bytes (used: %d, target: %d)", len(sizes), excess, used, target)
+	case float64(used) < float64(target)*(1-adaptiveHysteresis):
+		for g, configured := range a.groups {
+			capacity := g.mainCache.getCapacity()
+			if capacity <= 0 || capacity == configured {
+				continue
+			}
+			capacity += max(int64(float64(capacity)*adaptiveGrowth), minAdaptiveGrowth)
+			switch {
+			case configured > 0:
+				capacity = min(capacity, configured)
+			case g.mainCache.size() < capacity/2:
+				// The capacity no longer limits the group.
+				capacity = 0
+			}
+			g.mainCache.setCapacity(capacity)
+		}
+	}
+}
+
+// configure changes the capacity g grows back to.
+func (a *Adaptive) configure(g *Group, capacity int64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, ok := a.groups[g]; ok {
+		a.groups[g] = capacity
+	}
+}
+
+// leave stops adjusting the capacity of g.
+func (a *Adaptive) leave(g *Group) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.groups, g)
+}
+
+// memoryLimit returns the lower of GOMEMLIMIT and the cgroup memory limit, or 0 if there is none.
+func memoryLimit() int64 {
+	limit := debug.SetMemoryLimit(-1)
+	for _, path := range []string{
+		"/sys/fs/cgroup/memory.max",                   // cgroup v2
+		"/sys/fs/cgroup/memory/memory.limit_in_bytes", // cgroup v1
+	} {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		if v, err := strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64); err == nil && v > 0 {
+			limit = min(limit, v)
+		}
+		break
+	}
+	if limit == math.MaxInt64 {
+		return 0
+	}
+	return limit
+}
+
+// memoryUsed returns the memory used by the Go runtime the same way the memory limit accounts for it.
+func memoryUsed() int64 {
+	samples := []metrics.Sample{
+		{Name: "/memory/classes/total:bytes"},
+		{Name: "/memory/classes/heap/released:bytes"},
+	}
+	metrics.Read(samples)
+	return int64(samples[0].Value.Uint64() - samples[1].Value.Uint64())
+}
+
+// gcCycles returns the number of GC cycles completed by the Go runtime.
+func gcCycles() uint64 {
+	samples := []metrics.Sample{{Name: "/gc/cycles/total:gc-cycles"}}
+	metrics.Read(samples)
+	return samples[0].Value.Uint64()
+}
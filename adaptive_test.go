@@ -0,0 +1,117 @@
+package gocache
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAdaptive(t *testing.T) {
+	a := NewAdaptive(0.5)
+	var used int64
+	a.memoryLimit = func() int64 { return 32 << 10 }
+	a.memoryUsed = func() int64 { return used }
+	getter := GetterFunc(func(key string) ([]byte, error) {
+		return []byte(strings.Repeat("v", 1<<10)), nil
+	})
+	g := NewGroup("adaptive", 1<<30, getter, WithAdaptive(a))
+	defer g.Close()
+	for _, k := range []string{"k1", "k2", "k3", "k4"} {
+		g.Get(k)
+	}
+	size := g.mainCache.size()
+
+	// Over the middle of the hysteresis band under the target of 16KiB by half the size of the cache.
+	used = adaptiveGoal(16<<10) + size/2
+	a.Adjust()
+	if c := g.mainCache.getCapacity(); c != size-size/2 || g.mainCache.size() > c {
+		t.Fatalf("adaptive failed to shrink the cache (size: %d, capacity: %d)", size, c)
+	}
+
+	// Comfortably under the target.
+	used = 1 << 10
+	a.Adjust()
+	if c := g.mainCache.getCapacity(); c <= size-size/2 || c > 1<<30 {
+		t.Fatalf("adaptive failed to grow the cache (capacity: %d)", c)
+	}
+	for range 100 {
+		a.Adjust()
+	}
+	if c := g.mainCache.getCapacity(); c != 1<<30 {
+		t.Fatalf("adaptive failed to restore the configured capacity (capacity: %d)", c)
+	}
+}
+
+func TestAdaptiveUnlimited(t *testing.T) {
+	a := NewAdaptive(0.5)
+	var used int64
+	a.memoryLimit = func() int64 { return 32 << 10 }
+	a.memoryUsed = func() int64 { return used }
+	getter := GetterFunc(func(key string) ([]byte, error) {
+		return []byte(strings.Repeat("v", 1<<10)), nil
+	})
+	g := NewGroup("adaptive_unlimited", 0, getter, WithAdaptive(a))
+	empty := NewGroup("adaptive_empty", 1<<30, getter, WithAdaptive(a))
+	defer g.Close()
+	defer empty.Close()
+	for _, k := range []string{"k1", "k2", "k3", "k4"} {
+		g.Get(k)
+	}
+	size := g.mainCache.size()
+
+	used = adaptiveGoal(16<<10) + size/2
+	a.Adjust()
+	if c := empty.mainCache.getCapacity(); c != 1<<30 {
+		t.Fatalf("adaptive shrank an empty cache (capacity: %d)", c)
+	}
+	if c := g.mainCache.getCapacity(); c != size-size/2 {
+		t.Fatalf("adaptive failed to shrink the unlimited cache (size: %d, capacity: %d)", size, c)
+	}
+
+	used = 1 << 10
+	a.Adjust()
+	if c := g.mainCache.getCapacity(); c != 0 {
+		t.Fatalf("adaptive failed to lift the limit of the unlimited cache (capacity: %d)", c)
+	}
+}
+
+func TestAdaptiveShrinkOnce(t *testing.T) {
+	a := NewAdaptive(0.5)
+	var cycles uint64
+	a.memoryLimit = func() int64 { return 32 << 10 }
+	a.gcCycles = func() uint64 { return cycles }
+	getter := GetterFunc(func(key string) ([]byte, error) {
+		return []byte(strings.Repeat("v", 1<<10)), nil
+	})
+	g := NewGroup("adaptive_shrink_once", 1<<30, getter, WithAdaptive(a))
+	defer g.Close()
+	for _, k := range []string{"k1", "k2", "k3", "k4"} {
+		g.Get(k)
+	}
+	size := g.mainCache.size()
+
+	// The memory freed by the shrink is not given back until a GC cycle completes.
+	used := adaptiveGoal(16<<10) + size/4
+	a.memoryUsed = func() int64 { return used }
+	a.Adjust()
+	shrunk := g.mainCache.getCapacity()
+	if shrunk >= size {
+		t.Fatalf("adaptive failed to shrink the cache (size: %d, capacity: %d)", size, shrunk)
+	}
+	for range 10 {
+		a.Adjust()
+	}
+	if c := g.mainCache.getCapacity(); c != shrunk {
+		t.Fatalf("adaptive shrank the cache again before a GC (expected: %d, got: %d)", shrunk, c)
+	}
+
+	cycles++
+	a.Adjust()
+	if c := g.mainCache.getCapacity(); c >= shrunk {
+		t.Fatalf("adaptive failed to shrink the cache after a GC (before: %d, capacity: %d)", shrunk, c)
+	}
+}
+
+// adaptiveGoal returns the memory use an Adaptive shrinks the capacities towards for target.
+func adaptiveGoal(target int64) int64 {
+	return int64(float64(target) * (1 - adaptiveHysteresis/2))
+}
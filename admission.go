@@ -0,0 +1,95 @@
+package gocache
+
+import (
+	"compress/gzip"
+	"log"
+)
+
+// An Admission decides what a group does with a value too large for its main cache.
+type Admission int
+
+const (
+	AdmitReject     Admission = iota // the value is not cached
+	AdmitLarge                       // the value is cached in the large-object area, see WithLargeObjectArea
+	AdmitCompressed                  // the value is cached compressed if it then fits, and rejected otherwise
+)
+
+// WithMaxEntrySize limits the size of the entries of the main cache, len(key) + len(value), to size bytes,
+// and makes the group handle larger values with admission.
+// Without it, an entry is only limited by the capacity of its shard, and larger values are rejected
+// rather than evicting the whole shard. The rejected values are counted in Stats.
+func WithMaxEntrySize(size int64, admission Admission) GroupOption {
+	return func(g *Group) {
+		g.maxEntrySize = size
+		g.admission = admission
+	}
+}
+
+// WithLargeObjectArea gives the group a separate LRU cache of capacity bytes for the values admitted by AdmitLarge,
+// so that they do not evict many small entries of the main cache. Without it, AdmitLarge rejects the values.
+func WithLargeObjectArea(capacity int64) GroupOption {
+	return func(g *Group) {
+		g.largeObjects = &cache{capacity: capacity}
+	}
+}
+
+// defaultCompressor compresses the values admitted by AdmitCompressed if the group has no compressor.
+var defaultCompressor = Gzip(gzip.DefaultCompression)
+
+// lookupCache gets the value of key from the pinned entries, the main cache or the large-object area.
+// An expired value is removed from the group and missed.
+func (g *Group) lookupCache(key string) (ByteView, bool) {
+	v, ok := g.getPinned(key)
+	if !ok {
+		v, ok = g.mainCache.get(key)
+	}
+	if !ok && g.largeObjects != nil {
+		v, ok = g.largeObjects.get(key)
+	}
+	if ok && v.expired() {
+		g.expire(key)
+		return ByteView{}, false
+	}
+	return v, ok
+}
+
+// populateCache stores the value in the cache of the group if it is admitted.
+func (g *Group) populateCache(key string, value ByteView) {
+	limit := g.entryLimit()
+	if limit <= 0 || entrySize(key, value) <= limit {
+		g.mainCache.set(key, value)
+		return
+	}
+	switch g.admission {
+	case AdmitLarge:
+		if g.largeObjects != nil {
+			if capacity := g.largeObjects.getCapacity(); capacity <= 0 || entrySize(key, value) <= capacity {
+				g.largeObjects.set(key, value)
+				return
+			}
+		}
+	case AdmitCompressed:
+		if value.c == nil {
+			compressor := g.compressor
+			if compressor == nil {
+				compressor = defaultCompressor
+			}
+			value = compressWith(compressor, value)
+		}
+		if entrySize(key, value) <= limit {
+			g.mainCache.set(key, value)
+			return
+		}
+	}
+	g.stats.rejected.Add(1)
+	log.Printf("[gocache] reject key=%s of %d bytes", key, value.Len())
+}
+
+// entryLimit returns the maximum size of an entry of the main cache; <= 0 means no limit.
+func (g *Group) entryLimit() int64 {
+	limit := g.mainCache.shardCapacity(g.mainCache.getCapacity())
+	if g.maxEntrySize > 0 && (limit <= 0 || g.maxEntrySize < limit) {
+		limit = g.maxEntrySize
+	}
+	return limit
+}
@@ -0,0 +1,61 @@
+package gocache
+
+import (
+	"strings"
+	"testing"
+)
+
+// largeGetter returns 100 bytes for the keys starting with "large", and the key otherwise.
+var largeGetter = GetterFunc(func(key string) ([]byte, error) {
+	if strings.HasPrefix(key, "large") {
+		return []byte(strings.Repeat("a", 100)), nil
+	}
+	return []byte(key), nil
+})
+
+func TestAdmitReject(t *testing.T) {
+	g := NewGroup("admit_reject", 64, largeGetter)
+	defer g.Close()
+
+	g.Get("k1")
+	g.Get("k2")
+	if v, err := g.Get("large"); err != nil || v.Len() != 100 {
+		t.Fatalf("group failed to load the large value")
+	}
+	if g.mainCache.len() != 2 {
+		t.Fatalf("large value flushed the cache (expected: %v, got: %v)", 2, g.mainCache.len())
+	}
+	if _, ok := g.lookupCache("large"); ok || g.Stats().Rejected != 1 {
+		t.Fatalf("group failed to reject the large value (rejected: %d)", g.Stats().Rejected)
+	}
+}
+
+func TestAdmitLarge(t *testing.T) {
+	g := NewGroup("admit_large", 1<<10, largeGetter, WithMaxEntrySize(32, AdmitLarge), WithLargeObjectArea(1<<10))
+	defer g.Close()
+
+	g.Get("k1")
+	g.Get("large")
+	if _, ok := g.mainCache.get("large"); ok {
+		t.Fatalf("group cached the large value in the main cache")
+	}
+	if v, ok := g.lookupCache("large"); !ok || v.Len() != 100 || g.Stats().Rejected != 0 {
+		t.Fatalf("group failed to cache the large value in the large-object area")
+	}
+	if v, err := g.Get("large"); err != nil || v.Len() != 100 || g.Stats().Hits != 1 {
+		t.Fatalf("group failed to hit the large-object area")
+	}
+}
+
+func TestAdmitCompressed(t *testing.T) {
+	g := NewGroup("admit_compressed", 1<<10, largeGetter, WithMaxEntrySize(64, AdmitCompressed))
+	defer g.Close()
+
+	g.Get("large")
+	if v, ok := g.mainCache.get("large"); !ok || v.c == nil || v.Len() >= 100 {
+		t.Fatalf("group failed to cache the large value compressed")
+	}
+	if v, err := g.Get("large"); err != nil || v.String() != strings.Repeat("a", 100) {
+		t.Fatalf("group failed to decompress the large value")
+	}
+}
@@ -0,0 +1,237 @@
+package arc
+
+import (
+	"container/list"
+	"iter"
+)
+
+// The lists an entry can be in.
+const (
+	t1 = iota // resident entries seen once recently
+	t2        // resident entries seen at least twice recently
+	b1        // ghosts of entries evicted from t1
+	b2        // ghosts of entries evicted from t2
+)
+
+// An ARC (Adaptive Replacement Cache) cache with byte capacity.
+//
+// Resident entries are split between a recency list t1 and a frequency list t2.
+// The keys of evicted entries are remembered in the ghost lists b1 and b2, and a miss on a ghost
+// moves the target size of t1 towards the list that would have kept the entry,
+// so that the cache adapts between recency and frequency workloads.
+type Cache struct {
+	capacity int64                         // the maximum size of the cache; capacity <= 0 means no limit
+	target   int64                         // the adaptive target size of t1
+	lists    [4]sizedList                  // t1, t2, b1 and b2
+	cache    map[string]*list.Element      // the key to element mapping, including ghosts
+	onEvict  func(key string, value Value) // (optional) callback when an entry is evicted
+}
+
+// A sizedList is an LRU list of entries with their total size.
+type sizedList struct {
+	ll   *list.List
+	size int64
+}
+
+// The element in the linked lists. The KV pair of the cache, or the key and size of a ghost.
+type entry struct {
+	key   string
+	value Value // nil for ghosts
+	size  int64
+	list  int
+}
+
+// A Value in the cache implements the Len method to return its size in bytes.
+type Value interface {
+	Len() int // the size in bytes
+}
+
+// The constructor of Cache.
+func New(capacity int64, onEvict func(string, Value)) *Cache {
+	c := &Cache{
+		capacity: capacity,
+		cache:    make(map[string]*list.Element),
+		onEvict:  onEvict,
+	}
+	for i := range c.lists {
+		c.lists[i].ll = list.New()
+	}
+	return c
+}
+
+// Get gets the value from the cache by key.
+func (c *Cache) Get(key string) (Value, bool) {
+	ele, ok := c.cache[key]
+	if !ok || isGhost(ele) {
+		return nil, false
+	}
+	ele = c.move(ele, t2)
+	return ele.Value.(*entry).value, true
+}
+
+// Peek gets the value of key from the cache without updating its recency or frequency.
+func (c *Cache) Peek(key string) (Value, bool) {
+	ele, ok := c.cache[key]
+	if !ok || isGhost(ele) {
+		return nil, false
+	}
+	return ele.Value.(*entry).value, true
+}
+
+// Set sets a value with a key in the cache.
+//
+// An entry larger than the capacity is rejected rather than evicting the whole cache,
+// and the previous value of key, if any, is removed.
+func (c *Cache) Set(key string, value Value) {
+	size := int64(len(key)) + int64(value.Len())
+	if c.capacity > 0 && size > c.capacity {
+		c.Remove(key)
+		return
+	}
+	ele, ok := c.cache[key]
+	switch {
+	case ok && !isGhost(ele):
+		kv := ele.Value.(*entry)
+		c.lists[kv.list].size += size - kv.size
+		kv.value, kv.size = value, size
+		c.move(ele, t2)
+		c.replace(false)
+	case ok && ele.Value.(*entry).list == b1:
+		// Recency would have kept the entry: grow t1.
+		delta := max(c.lists[b2].size/max(c.lists[b1].size, 1), 1) * size
+		c.target = min(c.target+delta, c.capacity)
+		c.revive(ele, value, size)
+		c.replace(false)
+	case ok && ele.Value.(*entry).list == b2:
+		// Frequency would have kept the entry: shrink t1.
+		delta := max(c.lists[b1].size/max(c.lists[b2].size, 1), 1) * size
+		c.target = max(c.target-delta, 0)
+		c.revive(ele, value, size)
+		c.replace(true)
+	default:
+		c.cache[key] = c.push(&entry{key: key, value: value, size: size}, t1)
+		c.replace(false)
+		c.trimGhosts()
+	}
+}
+
+// Remove removes the entry of key from the cache.
+// The onEvict callback is called for the removed entry.
+func (c *Cache) Remove(key string) {
+	ele, ok := c.cache[key]
+	if !ok || isGhost(ele) {
+		return
+	}
+	kv := c.unlink(ele)
+	delete(c.cache, key)
+	if c.onEvict != nil {
+		c.onEvict(kv.key, kv.value)
+	}
+}
+
+// Len returns the number of cache entries.
+func (c *Cache) Len() int {
+	return c.lists[t1].ll.Len() + c.lists[t2].ll.Len()
+}
+
+// Size returns the size of the cache entries in bytes.
+func (c *Cache) Size() int64 {
+	return c.lists[t1].size + c.lists[t2].size
+}
+
+// Resize changes the capacity of the cache, evicting entries if it shrinks.
+func (c *Cache) Resize(capacity int64) {
+	c.capacity = capacity
+	c.target = min(c.target, max(capacity, 0))
+	c.replace(false)
+	c.trimGhosts()
+}
+
+// Backward returns an iterator over the cache entries from the first to the last to be evicted:
+// the entries of t1 and then of t2, each from the least to the most recently used.
+func (c *Cache) Backward() iter.Seq2[string, Value] {
+	return func(yield func(string, Value) bool) {
+		for _, l := range []int{t1, t2} {
+			for ele := c.lists[l].ll.Back(); ele != nil; ele = ele.Prev() {
+				kv := ele.Value.(*entry)
+				if !yield(kv.key, kv.value) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// replace evicts resident entries to their ghost lists until the cache fits its capacity.
+// t1 gives up its entries when it is over its target size, or at its target if the miss was in b2.
+func (c *Cache) replace(inB2 bool) {
+	for c.capacity > 0 && c.Size() > c.capacity {
+		from, to := t2, b2
+		if t1Size := c.lists[t1].size; t1Size > 0 &&
+			(t1Size > c.target || inB2 && t1Size == c.target || c.lists[t2].size == 0) {
+			from, to = t1, b1
+		}
+		ele := c.lists[from].ll.Back()
+		kv := ele.Value.(*entry)
+		value := kv.value
+		kv.value = nil
+		c.move(ele, to)
+		if c.onEvict != nil {
+			c.onEvict(kv.key, value)
+		}
+	}
+}
+
+// trimGhosts keeps t1 and b1 within the capacity, and all the lists within twice the capacity.
+func (c *Cache) trimGhosts() {
+	if c.capacity <= 0 {
+		return
+	}
+	for c.lists[t1].size+c.lists[b1].size > c.capacity && c.lists[b1].ll.Len() > 0 {
+		c.forget(c.lists[b1].ll.Back())
+	}
+	for c.Size()+c.lists[b1].size+c.lists[b2].size > 2*c.capacity && c.lists[b2].ll.Len() > 0 {
+		c.forget(c.lists[b2].ll.Back())
+	}
+}
+
+// revive turns a ghost back into a resident entry of t2.
+func (c *Cache) revive(ele *list.Element, value Value, size int64) {
+	kv := c.unlink(ele)
+	kv.value, kv.size = value, size
+	c.cache[kv.key] = c.push(kv, t2)
+}
+
+// forget drops a ghost.
+func (c *Cache) forget(ele *list.Element) {
+	kv := c.unlink(ele)
+	delete(c.cache, kv.key)
+}
+
+// move moves an entry to the front of a list and returns its new element.
+func (c *Cache) move(ele *list.Element, l int) *list.Element {
+	ele = c.push(c.unlink(ele), l)
+	c.cache[ele.Value.(*entry).key] = ele
+	return ele
+}
+
+// push pushes an entry to the front of a list.
+func (c *Cache) push(kv *entry, l int) *list.Element {
+	kv.list = l
+	c.lists[l].size += kv.size
+	return c.lists[l].ll.PushFront(kv)
+}
+
+// unlink removes an element from its list.
+func (c *Cache) unlink(ele *list.Element) *entry {
+	kv := ele.Value.(*entry)
+	c.lists[kv.list].ll.Remove(ele)
+	c.lists[kv.list].size -= kv.size
+	return kv
+}
+
+// isGhost reports whether the element is a ghost.
+func isGhost(ele *list.Element) bool {
+	l := ele.Value.(*entry).list
+	return l == b1 || l == b2
+}
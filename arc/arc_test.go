@@ -0,0 +1,86 @@
+package arc
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+type value string
+
+func (v value) Len() int {
+	return len(v)
+}
+
+func TestGet(t *testing.T) {
+	c := New(int64(0), nil)
+	c.Set("k1", value("v1"))
+	if v, ok := c.Get("k1"); !ok || string(v.(value)) != "v1" {
+		t.Fatalf("cache hit k1=v1 failed")
+	}
+	if _, ok := c.Get("k2"); ok {
+		t.Fatalf("cache miss k2 failed")
+	}
+	c.Set("k1", value("value1"))
+	if c.Size() != int64(len("k1"+"value1")) || c.Len() != 1 {
+		t.Fatalf("cache set failed (expected: %v, got: %v)", len("k1"+"value1"), c.Size())
+	}
+}
+
+func TestEvict(t *testing.T) {
+	keys := []string{}
+	c := New(int64(len("k1"+"v1")*2), func(key string, value Value) {
+		keys = append(keys, key)
+	})
+	c.Set("k1", value("v1"))
+	c.Set("k2", value("v2"))
+	c.Get("k1") // k1 moves to t2
+	c.Set("k3", value("v3"))
+	if _, ok := c.Get("k1"); !ok || c.Len() != 2 {
+		t.Fatalf("cache evicted the frequently used k1")
+	}
+	if expected := []string{"k2"}; !reflect.DeepEqual(expected, keys) {
+		t.Fatalf("cache onEvict callback failed (expected: %v, got: %v)", expected, keys)
+	}
+	if _, ok := c.Get("k2"); ok {
+		t.Fatalf("cache hit a ghost")
+	}
+}
+
+func TestAdapt(t *testing.T) {
+	c := New(int64(100), nil)
+	for i := range 10 {
+		c.Set(fmt.Sprintf("f%02d", i), value("v"))
+		c.Get(fmt.Sprintf("f%02d", i))
+	}
+	for i := range 30 {
+		c.Set(fmt.Sprintf("r%02d", i), value("v"))
+	}
+	if c.lists[t2].ll.Len() != 10 || c.lists[b1].ll.Len() == 0 {
+		t.Fatalf("cache failed to keep the frequently used entries in t2")
+	}
+	// Setting again a key evicted from t1 grows the target size of t1.
+	c.Set("r14", value("v"))
+	if c.target == 0 || c.lists[t2].ll.Len() != 11 {
+		t.Fatalf("cache failed to adapt to a b1 ghost hit (target: %d)", c.target)
+	}
+	if c.Size() > 100 {
+		t.Fatalf("cache capacity exceeded (size: %d)", c.Size())
+	}
+	c.Resize(50)
+	if c.Size() > 50 || c.lists[t1].size+c.lists[b1].size > 50 {
+		t.Fatalf("cache resize failed (size: %d)", c.Size())
+	}
+}
+
+func TestRemove(t *testing.T) {
+	keys := []string{}
+	c := New(int64(0), func(key string, value Value) {
+		keys = append(keys, key)
+	})
+	c.Set("k1", value("v1"))
+	c.Remove("k1")
+	if _, ok := c.Get("k1"); ok || c.Len() != 0 || len(keys) != 1 {
+		t.Fatalf("cache remove k1 failed")
+	}
+}
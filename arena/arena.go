@@ -0,0 +1,349 @@
+package arena
+
+import (
+	"encoding/binary"
+	"hash/maphash"
+	"iter"
+	"math"
+	"unsafe"
+)
+
+const (
+	headerSize  = 5       // the record length and the flags
+	minBuffer   = 1 << 16 // the initial size of the buffer
+	maxCapacity = math.MaxUint32
+)
+
+// The flags of a record.
+const (
+	flagDeleted = 1 << iota // the record is a removed or replaced entry
+	flagWrap                // the record fills the end of the buffer, and the next one is at its start
+)
+
+// A Cache stores its entries in a single ring buffer of bytes, indexed by a map from key hashes to offsets.
+//
+// Neither the buffer nor the index contain pointers, so the garbage collector does not scan them,
+// however many entries the cache holds. Entries are evicted in FIFO order as new ones need space,
+// and removed or replaced entries leave holes reclaimed when the oldest entries reach them.
+// The buffer grows up to the capacity, so the capacity <= 4 GiB.
+//
+// Get copies the value out of the buffer and does not change the cache, so it is safe to call Get
+// concurrently with other calls of Get, but not with the other methods.
+//
+// A record is laid out as
+//
+//	uint32 record length | uint8 flags | uvarint key length | uvarint meta length | key | meta | value
+//
+// where meta is small opaque data stored along the value.
+type Cache struct {
+	capacity int64 // the maximum size of the buffer; capacity <= 0 means no limit
+	buf      []byte
+	head     int               // the offset of the oldest record
+	tail     int               // the offset of the next record
+	used     int64             // the bytes from head to tail, including holes
+	live     int64             // the bytes of the live records
+	size     int64             // the size of the entries, len(key) + len(value)
+	index    map[uint64]uint32 // the key hash to record offset mapping
+	seed     maphash.Seed
+	onEvict  func(key string, value, meta []byte) // (optional) callback when an entry is evicted
+}
+
+// EntryOverhead returns the estimated memory used by a Cache for each entry in addition to its key and value:
+// the header of its record with short key and meta lengths, and its slot in the index.
+func EntryOverhead() int64 {
+	header := int64(headerSize + 2)
+	slot := int64(unsafe.Sizeof(uint64(0))+unsafe.Sizeof(uint32(0))) + 1 // the hash, the offset and a control byte
+	// A map is at most 7/8 full, and half full right after growing.
+	return header + slot*3/2
+}
+
+// The constructor of Cache.
+func New(capacity int64, onEvict func(key string, value, meta []byte)) *Cache {
+	return &Cache{
+		capacity: min(capacity, maxCapacity),
+		index:    make(map[uint64]uint32),
+		seed:     maphash.MakeSeed(),
+		onEvict:  onEvict,
+	}
+}
+
+// A record is an entry read from the buffer. Its slices alias the buffer.
+type record struct {
+	off    int
+	length int
+	flags  byte
+	key    []byte
+	meta   []byte
+	value  []byte
+}
+
+// Get gets the value and meta from the cache by key. They are copies of the data in the buffer.
+func (c *Cache) Get(key string) (value, meta []byte, ok bool) {
+	off, ok := c.index[maphash.String(c.seed, key)]
+	if !ok {
+		return nil, nil, false
+	}
+	r := c.read(int(off))
+	if string(r.key) != key {
+		return nil, nil, false
+	}
+	return clone(r.value), clone(r.meta), true
+}
+
+// Contains reports whether key is in the cache.
+func (c *Cache) Contains(key string) bool {
+	off, ok := c.index[maphash.String(c.seed, key)]
+	return ok && string(c.read(int(off)).key) == key
+}
+
+// Set sets a value with a key and meta in the cache.
+// An entry larger than the capacity is rejected, and the previous value of key, if any, is removed.
+func (c *Cache) Set(key string, value, meta []byte) {
+	h := maphash.String(c.seed, key)
+	if off, ok := c.index[h]; ok {
+		// The previous value of key, or an entry of another key with the same hash, is replaced.
+		if r := c.read(int(off)); string(r.key) == key {
+			c.delete(h, int(off), false)
+		} else {
+			c.delete(h, int(off), true)
+		}
+	}
+	length := headerSize + uvarintLen(len(key)) + uvarintLen(len(meta)) + len(key) + len(meta) + len(value)
+	if !c.reserve(length) {
+		return
+	}
+	if len(c.buf)-c.tail < length {
+		c.wrap()
+	}
+	b := c.buf[c.tail : c.tail+length]
+	binary.LittleEndian.PutUint32(b, uint32(length))
+	b[4] = 0
+	n := headerSize
+	n += binary.PutUvarint(b[n:], uint64(len(key)))
+	n += binary.PutUvarint(b[n:], uint64(len(meta)))
+	n += copy(b[n:], key)
+	n += copy(b[n:], meta)
+	copy(b[n:], value)
+	c.index[h] = uint32(c.tail)
+	c.advanceTail(length)
+	c.live += int64(length)
+	c.size += int64(len(key) + len(value))
+}
+
+// Remove removes the entry of key from the cache.
+// The onEvict callback is called for the removed entry.
+func (c *Cache) Remove(key string) {
+	h := maphash.String(c.seed, key)
+	if off, ok := c.index[h]; ok && string(c.read(int(off)).key) == key {
+		c.delete(h, int(off), true)
+	}
+}
+
+// EvictOldest evicts the oldest entry and reports whether there was one.
+// The onEvict callback is called for the evicted entry.
+func (c *Cache) EvictOldest() bool {
+	n := len(c.index)
+	for len(c.index) == n && c.used > 0 {
+		c.evictHead()
+	}
+	return len(c.index) < n
+}
+
+// Len returns the number of cache entries.
+func (c *Cache) Len() int {
+	return len(c.index)
+}
+
+// Size returns the size of the cache entries, len(key) + len(value), in bytes.
+func (c *Cache) Size() int64 {
+	return c.size
+}
+
+// Resize changes the capacity of the cache, evicting the oldest entries if it shrinks.
+func (c *Cache) Resize(capacity int64) {
+	c.capacity = min(capacity, maxCapacity)
+	if c.capacity <= 0 || int64(len(c.buf)) <= c.capacity {
+		return
+	}
+	for c.live > c.capacity {
+		c.evictHead()
+	}
+	c.rebuild(int(c.capacity))
+}
+
+// An Entry is the value and the meta of a key, copied out of the buffer.
+type Entry struct {
+	Value []byte
+	Meta  []byte
+}
+
+// Backward returns an iterator over the cache entries from the oldest to the newest, i.e. in eviction order.
+func (c *Cache) Backward() iter.Seq2[string, Entry] {
+	return func(yield func(string, Entry) bool) {
+		for r := range c.records() {
+			if r.flags&flagDeleted == 0 && !yield(string(r.key), Entry{Value: clone(r.value), Meta: clone(r.meta)}) {
+				return
+			}
+		}
+	}
+}
+
+// records returns an iterator over the records from head to tail, except the wrap records.
+func (c *Cache) records() iter.Seq[record] {
+	return func(yield func(record) bool) {
+		off := c.head
+		for n := int64(0); n < c.used; {
+			if c.wrapsAt(off) {
+				n += int64(len(c.buf) - off)
+				off = 0
+				continue
+			}
+			r := c.read(off)
+			if !yield(r) {
+				return
+			}
+			n += int64(r.length)
+			off += r.length
+		}
+	}
+}
+
+// reserve makes room for a record of length bytes at the tail, growing the buffer or evicting the oldest entries.
+// It reports false if the record is larger than the capacity.
+func (c *Cache) reserve(length int) bool {
+	if c.capacity > 0 && int64(length) > c.capacity {
+		return false
+	}
+	for !c.fits(length) {
+		if c.capacity <= 0 || int64(len(c.buf)) < c.capacity {
+			size := max(2*len(c.buf), minBuffer, int(c.live)+length)
+			if c.capacity > 0 {
+				size = min(size, int(c.capacity))
+			}
+			c.rebuild(size)
+			continue
+		}
+		c.evictHead()
+	}
+	return true
+}
+
+// fits reports whether a record of length bytes can be written at the tail.
+func (c *Cache) fits(length int) bool {
+	free := int64(len(c.buf)) - c.used
+	if end := len(c.buf) - c.tail; c.tail >= c.head && end < length {
+		// The record goes to the start of the buffer, and the end is wasted.
+		return free-int64(end) >= int64(length)
+	}
+	return free >= int64(length)
+}
+
+// wrap marks the end of the buffer as unused and moves the tail to its start.
+func (c *Cache) wrap() {
+	end := len(c.buf) - c.tail
+	if end >= headerSize {
+		binary.LittleEndian.PutUint32(c.buf[c.tail:], uint32(end))
+		c.buf[c.tail+4] = flagWrap
+	}
+	c.used += int64(end)
+	c.tail = 0
+}
+
+// advanceTail moves the tail past a record of length bytes.
+func (c *Cache) advanceTail(length int) {
+	c.used += int64(length)
+	c.tail += length
+	if c.tail == len(c.buf) {
+		c.tail = 0
+	}
+}
+
+// wrapsAt reports whether the record at off is at the start of the buffer.
+func (c *Cache) wrapsAt(off int) bool {
+	return len(c.buf)-off < headerSize || c.buf[off+4]&flagWrap != 0
+}
+
+// evictHead drops the oldest record, evicting its entry if it is live.
+func (c *Cache) evictHead() {
+	if c.wrapsAt(c.head) {
+		c.used -= int64(len(c.buf) - c.head)
+		c.head = 0
+		return
+	}
+	r := c.read(c.head)
+	if r.flags&flagDeleted == 0 {
+		c.delete(maphash.Bytes(c.seed, r.key), c.head, true)
+	}
+	c.used -= int64(r.length)
+	c.head += r.length
+	if c.head == len(c.buf) || c.used == 0 {
+		c.head = 0
+	}
+	if c.used == 0 {
+		c.tail = 0
+	}
+}
+
+// delete marks the live record at off as deleted and removes it from the index,
+// calling onEvict for its entry if evict is true.
+func (c *Cache) delete(h uint64, off int, evict bool) {
+	r := c.read(off)
+	c.buf[off+4] |= flagDeleted
+	delete(c.index, h)
+	c.live -= int64(r.length)
+	c.size -= int64(len(r.key) + len(r.value))
+	if evict && c.onEvict != nil {
+		c.onEvict(string(r.key), clone(r.value), clone(r.meta))
+	}
+}
+
+// rebuild copies the live records to the start of a new buffer of size bytes, dropping the holes.
+func (c *Cache) rebuild(size int) {
+	buf := make([]byte, size)
+	var n int
+	for r := range c.records() {
+		if r.flags&flagDeleted != 0 {
+			continue
+		}
+		copy(buf[n:], c.buf[r.off:r.off+r.length])
+		c.index[maphash.Bytes(c.seed, r.key)] = uint32(n)
+		n += r.length
+	}
+	c.buf = buf
+	c.head = 0
+	c.tail = n % max(size, 1)
+	c.used = int64(n)
+}
+
+// read reads the record at off.
+func (c *Cache) read(off int) record {
+	b := c.buf[off:]
+	r := record{off: off, length: int(binary.LittleEndian.Uint32(b)), flags: b[4]}
+	b = b[:r.length]
+	n := headerSize
+	keyLen, k := binary.Uvarint(b[n:])
+	n += k
+	metaLen, k := binary.Uvarint(b[n:])
+	n += k
+	r.key = b[n : n+int(keyLen)]
+	n += int(keyLen)
+	r.meta = b[n : n+int(metaLen)]
+	n += int(metaLen)
+	r.value = b[n:]
+	return r
+}
+
+// uvarintLen returns the length of the uvarint encoding of x.
+func uvarintLen(x int) int {
+	n := 1
+	for ; x >= 0x80; x >>= 7 {
+		n++
+	}
+	return n
+}
+
+func clone(b []byte) []byte {
+	c := make([]byte, len(b))
+	copy(c, b)
+	return c
+}
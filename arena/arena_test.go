@@ -0,0 +1,135 @@
+package arena
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestGet(t *testing.T) {
+	c := New(int64(0), nil)
+	c.Set("k1", []byte("v1"), []byte("m1"))
+	if v, m, ok := c.Get("k1"); !ok || string(v) != "v1" || string(m) != "m1" {
+		t.Fatalf("cache hit k1=v1 failed")
+	}
+	if _, _, ok := c.Get("k2"); ok {
+		t.Fatalf("cache miss k2 failed")
+	}
+	c.Set("k1", []byte("value1"), nil)
+	if v, _, ok := c.Get("k1"); !ok || string(v) != "value1" {
+		t.Fatalf("cache replace k1=value1 failed")
+	}
+	if c.Size() != int64(len("k1"+"value1")) || c.Len() != 1 {
+		t.Fatalf("cache set failed (expected: %v, got: %v)", len("k1"+"value1"), c.Size())
+	}
+}
+
+func TestEvict(t *testing.T) {
+	keys := []string{}
+	// Every record is 5 + 1 + 1 + 3 + 5 = 15 bytes.
+	c := New(int64(15*10), func(key string, value, meta []byte) {
+		keys = append(keys, key)
+	})
+	for i := range 100 {
+		c.Set(fmt.Sprintf("k%02d", i), []byte(fmt.Sprintf("v%04d", i)), nil)
+	}
+	if c.Len() != 10 || len(keys) != 90 || keys[0] != "k00" || keys[89] != "k89" {
+		t.Fatalf("cache FIFO eviction failed (len: %v, evicted: %v)", c.Len(), len(keys))
+	}
+	for i := 90; i < 100; i++ {
+		if v, _, ok := c.Get(fmt.Sprintf("k%02d", i)); !ok || string(v) != fmt.Sprintf("v%04d", i) {
+			t.Fatalf("cache hit k%02d failed", i)
+		}
+	}
+}
+
+func TestEvictOldest(t *testing.T) {
+	keys := []string{}
+	c := New(int64(0), func(key string, value, meta []byte) {
+		keys = append(keys, key)
+	})
+	c.Set("k1", []byte("v1"), nil)
+	c.Set("k2", []byte("v2"), nil)
+	c.Set("k3", []byte("v3"), nil)
+	c.Remove("k1")
+	if !c.EvictOldest() || c.Len() != 1 {
+		t.Fatalf("cache failed to evict the oldest entry (len: %v)", c.Len())
+	}
+	if expected := []string{"k1", "k2"}; !reflect.DeepEqual(expected, keys) {
+		t.Fatalf("cache onEvict callback failed (expected: %v, got: %v)", expected, keys)
+	}
+	c.EvictOldest()
+	if c.EvictOldest() || c.Len() != 0 {
+		t.Fatalf("cache evicted from an empty cache")
+	}
+}
+
+func TestWrap(t *testing.T) {
+	// Records of various lengths wrap around the end of the buffer at various offsets.
+	c := New(int64(1000), nil)
+	expected := map[string]string{}
+	for i := range 2000 {
+		key := fmt.Sprintf("k%d", i%50)
+		value := fmt.Sprint(make([]byte, i%37))
+		c.Set(key, []byte(value), nil)
+		expected[key] = value
+		if i%7 == 0 {
+			c.Remove(fmt.Sprintf("k%d", (i+25)%50))
+			delete(expected, fmt.Sprintf("k%d", (i+25)%50))
+		}
+	}
+	if c.used > 1000 || len(c.buf) != 1000 {
+		t.Fatalf("cache exceeded its capacity (used: %v)", c.used)
+	}
+	var size int64
+	for key, e := range c.Backward() {
+		if expected[key] != string(e.Value) {
+			t.Fatalf("cache entry %v corrupted (expected: %q, got: %q)", key, expected[key], e.Value)
+		}
+		size += int64(len(key) + len(e.Value))
+	}
+	if size != c.Size() {
+		t.Fatalf("cache size failed (expected: %v, got: %v)", size, c.Size())
+	}
+}
+
+func TestRemove(t *testing.T) {
+	keys := []string{}
+	c := New(int64(0), func(key string, value, meta []byte) {
+		keys = append(keys, key)
+	})
+	c.Set("k1", []byte("v1"), nil)
+	c.Set("k2", []byte("v2"), nil)
+	c.Remove("k1")
+	c.Remove("k3")
+	if _, _, ok := c.Get("k1"); ok || c.Len() != 1 || c.Size() != int64(len("k2"+"v2")) {
+		t.Fatalf("cache remove k1 failed")
+	}
+	if expected := []string{"k1"}; !reflect.DeepEqual(expected, keys) {
+		t.Fatalf("cache remove callback failed (expected: %v, got: %v)", expected, keys)
+	}
+}
+
+func TestResize(t *testing.T) {
+	c := New(int64(0), nil)
+	for i := range 100 {
+		c.Set(fmt.Sprintf("k%02d", i), []byte(fmt.Sprintf("v%04d", i)), nil)
+	}
+	c.Remove("k99")
+	c.Resize(15 * 10)
+	if c.Len() != 10 || len(c.buf) != 15*10 {
+		t.Fatalf("cache resize failed (len: %v)", c.Len())
+	}
+	keys := []string{}
+	for k := range c.Backward() {
+		keys = append(keys, k)
+	}
+	if keys[0] != "k89" || keys[9] != "k98" {
+		t.Fatalf("cache resize kept the wrong entries: %v", keys)
+	}
+	// Entries larger than the capacity are rejected.
+	c.Set("k98", make([]byte, 1000), nil)
+	if _, _, ok := c.Get("k98"); ok || c.Len() != 9 {
+		t.Fatalf("cache rejection failed")
+	}
+}
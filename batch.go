@@ -0,0 +1,149 @@
+package gocache
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// A BatchGetter is a Getter that can load the values of many keys in one call, see WithBatching.
+type BatchGetter interface {
+	Getter
+	// GetBatch loads the values of keys: results[i] and errs[i] are the ones of keys[i].
+	// errs may be nil if every value is loaded.
+	GetBatch(keys []string) (results []LoadResult, errs []error)
+}
+
+// A BatchGetterFunc implements BatchGetter with a function.
+type BatchGetterFunc func(keys []string) ([]LoadResult, []error)
+
+func (f BatchGetterFunc) Get(key string) ([]byte, error) {
+	results, errs := f([]string{key})
+	if len(errs) > 0 && errs[0] != nil {
+		return nil, errs[0]
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("no value for key=%s", key)
+	}
+	return results[0].Value.ByteSlice(), nil
+}
+
+func (f BatchGetterFunc) GetBatch(keys []string) ([]LoadResult, []error) {
+	return f(keys)
+}
+
+// WithBatching makes the group collect the keys missing at the same time for window,
+// and load them with one call of the GetBatch method of its getter, which must implement BatchGetter.
+// A batch is loaded as soon as it has maxBatch keys; maxBatch <= 0 means no limit.
+// Concurrent Gets of the same key are still deduplicated before batching.
+func WithBatching(window time.Duration, maxBatch int) GroupOption {
+	return func(g *Group) {
+		getter, ok := g.getter.(BatchGetter)
+		if !ok {
+			log.Printf("[gocache] batching ignored for group=%s: the getter is not a BatchGetter", g.name)
+			return
+		}
+		g.getter = &batcher{
+			BatchGetter: getter,
+			window:      window,
+			maxBatch:    maxBatch,
+			batches:     &g.stats.batches,
+		}
+	}
+}
+
+// A batcher is a ResultGetter loading the keys with a BatchGetter in batches.
+type batcher struct {
+	BatchGetter
+	window   time.Duration
+	maxBatch int
+	batches  *atomic.Int64 // counts the calls of GetBatch
+
+	mu      sync.Mutex
+	pending *batch // the batch collecting keys; nil if there is none
+}
+
+// A batch is a set of keys loaded together.
+type batch struct {
+	keys    []string
+	results []LoadResult
+	errs    []error
+	elapsed time.Duration // the latency of GetBatch
+	done    chan struct{} // closed when the batch is loaded
+}
+
+// GetResult adds key to the pending batch, and returns its result once the batch is loaded.
+func (b *batcher) GetResult(key string) (LoadResult, error) {
+	r, _, err := b.getResult(key)
+	return r, err
+}
+
+// getResult is GetResult also returning the latency of loading the batch of key, without waiting for the batch.
+func (b *batcher) getResult(key string) (LoadResult, time.Duration, error) {
+	b.mu.Lock()
+	bt := b.pending
+	if bt == nil {
+		bt = &batch{done: make(chan struct{})}
+		b.pending = bt
+		time.AfterFunc(b.window, func() { b.flush(bt) })
+	}
+	i := len(bt.keys)
+	bt.keys = append(bt.keys, key)
+	full := b.maxBatch > 0 && len(bt.keys) >= b.maxBatch
+	if full {
+		b.pending = nil
+	}
+	b.mu.Unlock()
+
+	if full {
+		b.load(bt)
+	}
+	<-bt.done
+	return bt.results[i], bt.elapsed, bt.errs[i]
+}
+
+// flush loads bt at the end of its window, unless it has been loaded for its size.
+func (b *batcher) flush(bt *batch) {
+	b.mu.Lock()
+	if b.pending != bt {
+		b.mu.Unlock()
+		return
+	}
+	b.pending = nil
+	b.mu.Unlock()
+	b.load(bt)
+}
+
+// load loads the keys of bt and fans the results out to their callers.
+// A panic of GetBatch, which may run in a timer goroutine, fails every key of bt instead of crashing the process.
+func (b *batcher) load(bt *batch) {
+	bt.results = make([]LoadResult, len(bt.keys))
+	bt.errs = make([]error, len(bt.keys))
+	defer close(bt.done)
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("[gocache] batch getter panicked: %v", r)
+			bt.fail(fmt.Errorf("batch getter panicked: %v", r))
+		}
+	}()
+	b.batches.Add(1)
+	start := time.Now()
+	results, errs := b.GetBatch(bt.keys)
+	bt.elapsed = time.Since(start)
+	if len(results) != len(bt.keys) || (errs != nil && len(errs) != len(bt.keys)) {
+		bt.fail(fmt.Errorf("batch getter returned %d results and %d errors for %d keys", len(results), len(errs), len(bt.keys)))
+		return
+	}
+	copy(bt.results, results)
+	copy(bt.errs, errs)
+	log.Printf("[gocache] load a batch of %d keys", len(bt.keys))
+}
+
+// fail sets err as the error of every key of bt.
+func (bt *batch) fail(err error) {
+	for i := range bt.errs {
+		bt.errs[i] = err
+	}
+}
@@ -0,0 +1,149 @@
+package gocache
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// batchGetter records the sizes of its batches, fails the key "bad" and panics on the key "panic".
+type batchGetter struct {
+	mu    sync.Mutex
+	sizes []int
+}
+
+func (g *batchGetter) Get(key string) ([]byte, error) {
+	return nil, errors.New("not batched")
+}
+
+func (g *batchGetter) GetBatch(keys []string) ([]LoadResult, []error) {
+	g.mu.Lock()
+	g.sizes = append(g.sizes, len(keys))
+	g.mu.Unlock()
+	results := make([]LoadResult, len(keys))
+	errs := make([]error, len(keys))
+	for i, key := range keys {
+		if key == "panic" {
+			panic("panic key")
+		}
+		if key == "bad" {
+			errs[i] = errors.New("bad key")
+			continue
+		}
+		results[i] = LoadResult{Value: StringView("value of " + key)}
+	}
+	return results, errs
+}
+
+// getAll gets the keys concurrently and returns their errors by key.
+func getAll(t *testing.T, g *Group, keys []string) map[string]error {
+	var mu sync.Mutex
+	errs := map[string]error{}
+	var wg sync.WaitGroup
+	for _, key := range keys {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := g.Get(key)
+			if err == nil && v.String() != "value of "+key {
+				t.Errorf("group batched Get failed (expected: value of %v, got: %v)", key, v)
+			}
+			mu.Lock()
+			errs[key] = err
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return errs
+}
+
+func TestBatching(t *testing.T) {
+	getter := &batchGetter{}
+	// The batch is loaded once it is full, long before the end of its window.
+	g := NewGroup("batching", 1<<10, getter, WithBatching(time.Hour, 10))
+	defer g.Close()
+
+	keys := []string{"bad"}
+	for i := range 9 {
+		keys = append(keys, fmt.Sprintf("k%d", i))
+	}
+	errs := getAll(t, g, keys)
+	if !reflect.DeepEqual([]int{10}, getter.sizes) {
+		t.Fatalf("group batching failed (expected: %v, got: %v)", []int{10}, getter.sizes)
+	}
+	for key, err := range errs {
+		if (err != nil) != (key == "bad") {
+			t.Fatalf("group batched Get of %v failed: %v", key, err)
+		}
+	}
+	if stats := g.Stats(); stats.Batches != 1 || stats.LocalLoads != 9 {
+		t.Fatalf("group batching stats failed (batches: %v, local loads: %v)", stats.Batches, stats.LocalLoads)
+	}
+	if _, err := g.Get("k0"); err != nil || len(getter.sizes) != 1 {
+		t.Fatalf("group failed to cache the batched values")
+	}
+}
+
+func TestMaxBatch(t *testing.T) {
+	getter := &batchGetter{}
+	g := NewGroup("max_batch", 1<<10, getter, WithBatching(50*time.Millisecond, 4))
+	defer g.Close()
+
+	keys := []string{}
+	for i := range 10 {
+		keys = append(keys, fmt.Sprintf("k%d", i))
+	}
+	getAll(t, g, keys)
+	// How the keys fall into batches depends on when they arrive; only the batch size is bounded.
+	total := 0
+	for _, size := range getter.sizes {
+		if size > 4 {
+			t.Fatalf("group max batch failed (sizes: %v)", getter.sizes)
+		}
+		total += size
+	}
+	if total != 10 || len(getter.sizes) < 3 {
+		t.Fatalf("group max batch failed to load every key (sizes: %v)", getter.sizes)
+	}
+}
+
+func TestBatchPanic(t *testing.T) {
+	getter := &batchGetter{}
+	g := NewGroup("batch_panic", 1<<10, getter, WithBatching(time.Millisecond, 0))
+	defer g.Close()
+
+	// The batch is loaded by the timer of its window.
+	if _, err := g.Get("panic"); err == nil {
+		t.Fatalf("group batched Get of a panicking batch succeeded")
+	}
+	if v, err := g.Get("key"); err != nil || v.String() != "value of key" {
+		t.Fatalf("group batching failed after a panic (got: %v, err: %v)", v, err)
+	}
+}
+
+func TestBatchMismatch(t *testing.T) {
+	g := NewGroup("batch_mismatch", 1<<10, BatchGetterFunc(
+		func(keys []string) ([]LoadResult, []error) {
+			return nil, nil
+		}), WithBatching(time.Millisecond, 0))
+	defer g.Close()
+
+	if _, err := g.Get("key"); err == nil {
+		t.Fatalf("group accepted a batch without results")
+	}
+}
+
+func TestBatchCost(t *testing.T) {
+	getter := &batchGetter{}
+	g := NewGroup("batch_cost", 1<<10, getter, WithBatching(50*time.Millisecond, 0))
+	defer g.Close()
+
+	// The cost of a batched value excludes the wait for its batch.
+	g.Get("key")
+	if v, ok := g.mainCache.get("key"); !ok || v.cost <= 0 || v.cost >= 50*time.Millisecond {
+		t.Fatalf("group batched value cost failed (got: %v)", v.cost)
+	}
+}
@@ -0,0 +1,201 @@
+package gocache
+
+import (
+	"container/list"
+	"sync"
+)
+
+// A Budget is a memory budget in bytes shared by the caches of multiple groups.
+// It keeps track of the recency of the entries across all its groups,
+// and when the budget is exceeded the globally least recently used entries are evicted.
+//
+// Each group may reserve a minimum it never gives up and has a weight.
+// The age of a group's entries is divided by its weight when looking for the coldest entry,
+// so that a group of weight 2 keeps its entries about twice as long as a group of weight 1.
+type Budget struct {
+	mu     sync.Mutex
+	limit  int64                     // the maximum size of the groups; limit <= 0 means no limit
+	used   int64                     // the current size of the groups
+	clock  uint64                    // ticks on every access to an entry
+	shares map[*budgetShare]struct{} // the groups drawing from the budget
+}
+
+// A budgetShare is the part of a Budget a single group draws from.
+type budgetShare struct {
+	b      *Budget
+	c      *cache
+	min    int64   // the size never evicted by the budget
+	weight float64 // the relative weight of the group's entries
+	used   int64
+	ll     *list.List // the entries of the group from the most to the least recently used
+	elems  map[string]*list.Element
+}
+
+// A budgetEntry is an entry of a group in the budget.
+type budgetEntry struct {
+	key   string
+	size  int64
+	atime uint64 // the clock of the last access
+}
+
+// A victim is an entry picked to be evicted.
+type victim struct {
+	s   *budgetShare
+	key string
+}
+
+// NewBudget creates a Budget of limit bytes.
+func NewBudget(limit int64) *Budget {
+	return &Budget{
+		limit:  limit,
+		shares: make(map[*budgetShare]struct{}),
+	}
+}
+
+// WithBudget makes the group draw from the budget b.
+// The group never gives up the first min bytes of its cache to other groups,
+// and its weight sets its share of the budget relative to other groups; weight <= 0 means 1.
+// The capacity passed to NewGroup still bounds the group on its own.
+func WithBudget(b *Budget, min int64, weight float64) GroupOption {
+	return func(g *Group) {
+		if weight <= 0 {
+			weight = 1
+		}
+		s := &budgetShare{
+			b:      b,
+			c:      &g.mainCache,
+			min:    min,
+			weight: weight,
+			ll:     list.New(),
+			elems:  make(map[string]*list.Element),
+		}
+		b.mu.Lock()
+		b.shares[s] = struct{}{}
+		b.mu.Unlock()
+		g.mainCache.budget = s
+	}
+}
+
+// Limit returns the limit of the budget in bytes.
+func (b *Budget) Limit() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.limit
+}
+
+// SetLimit changes the limit of the budget, evicting entries if it shrinks.
+func (b *Budget) SetLimit(limit int64) {
+	b.mu.Lock()
+	b.limit = limit
+	b.mu.Unlock()
+	b.enforce()
+}
+
+// Used returns the size of the groups drawing from the budget in bytes.
+func (b *Budget) Used() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.used
+}
+
+// enforce evicts entries until the budget is no longer exceeded.
+// It must not be called with the lock of any cache held.
+func (b *Budget) enforce() {
+	for _, v := range b.victims() {
+		v.s.c.remove(v.key, EvictCapacity)
+	}
+}
+
+// victims picks the entries to be evicted for the budget not to be exceeded.
+// Each pick is the group's least recently used entry with the greatest weighted age.
+func (b *Budget) victims() []victim {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	over := b.used - b.limit
+	if b.limit <= 0 || over <= 0 {
+		return nil
+	}
+
+	next := make(map[*budgetShare]*list.Element, len(b.shares)) // the next candidate of each group
+	used := make(map[*budgetShare]int64, len(b.shares))
+	for s := range b.shares {
+		next[s] = s.ll.Back()
+		used[s] = s.used
+	}
+	var victims []victim
+	for over > 0 {
+		var coldest *budgetShare
+		var coldestAge float64
+		for s, ele := range next {
+			if ele == nil || used[s]-ele.Value.(*budgetEntry).size < s.min {
+				continue
+			}
+			age := float64(b.clock-ele.Value.(*budgetEntry).atime) / s.weight
+			if coldest == nil || age > coldestAge {
+				coldest, coldestAge = s, age
+			}
+		}
+		if coldest == nil {
+			break
+		}
+		e := next[coldest].Value.(*budgetEntry)
+		victims = append(victims, victim{s: coldest, key: e.key})
+		next[coldest] = next[coldest].Prev()
+		used[coldest] -= e.size
+		over -= e.size
+	}
+	return victims
+}
+
+// leave removes the group's entries from the budget.
+func (s *budgetShare) leave() {
+	s.b.mu.Lock()
+	defer s.b.mu.Unlock()
+	s.b.used -= s.used
+	s.used = 0
+	s.ll.Init()
+	s.elems = make(map[string]*list.Element)
+	delete(s.b.shares, s)
+}
+
+// add records a new or replaced entry of the group.
+func (s *budgetShare) add(key string, size int64) {
+	s.b.mu.Lock()
+	defer s.b.mu.Unlock()
+	s.b.clock++
+	if ele, ok := s.elems[key]; ok {
+		e := ele.Value.(*budgetEntry)
+		s.used += size - e.size
+		s.b.used += size - e.size
+		e.size, e.atime = size, s.b.clock
+		s.ll.MoveToFront(ele)
+		return
+	}
+	s.elems[key] = s.ll.PushFront(&budgetEntry{key: key, size: size, atime: s.b.clock})
+	s.used += size
+	s.b.used += size
+}
+
+// touch records an access to an entry of the group.
+func (s *budgetShare) touch(key string) {
+	s.b.mu.Lock()
+	defer s.b.mu.Unlock()
+	if ele, ok := s.elems[key]; ok {
+		s.b.clock++
+		ele.Value.(*budgetEntry).atime = s.b.clock
+		s.ll.MoveToFront(ele)
+	}
+}
+
+// remove records the removal of an entry of the group.
+func (s *budgetShare) remove(key string) {
+	s.b.mu.Lock()
+	defer s.b.mu.Unlock()
+	if ele, ok := s.elems[key]; ok {
+		e := ele.Value.(*budgetEntry)
+		s.ll.Remove(ele)
+		delete(s.elems, key)
+		s.used -= e.size
+		s.b.used -= e.size
+	}
+}
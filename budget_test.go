@@ -0,0 +1,101 @@
+package gocache
+
+import (
+	"testing"
+)
+
+func TestBudget(t *testing.T) {
+	// Every entry is 3 bytes, e.g. key=k1 and value=1.
+	getter := GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key[1:]), nil
+	})
+	b := NewBudget(12)
+	hot := NewGroup("budget_hot", 0, getter, WithBudget(b, 0, 1))
+	cold := NewGroup("budget_cold", 0, getter, WithBudget(b, 0, 1))
+	defer hot.Close()
+	defer cold.Close()
+
+	cold.Get("c1")
+	cold.Get("c2")
+	hot.Get("h1")
+	hot.Get("h2")
+	cold.Get("c1")
+	hot.Get("h3") // evicts c2, the globally least recently used entry
+	if _, ok := cold.mainCache.get("c2"); ok || b.Used() > b.Limit() {
+		t.Fatalf("budget failed to evict the globally least recently used entry (used: %d)", b.Used())
+	}
+	for _, k := range []string{"h1", "h2", "h3"} {
+		if _, ok := hot.mainCache.get(k); !ok {
+			t.Fatalf("budget evicted the recently used key=%s", k)
+		}
+	}
+}
+
+func TestBudgetShares(t *testing.T) {
+	getter := GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key[1:]), nil
+	})
+	b := NewBudget(12)
+	reserved := NewGroup("budget_reserved", 0, getter, WithBudget(b, 6, 1))
+	greedy := NewGroup("budget_greedy", 0, getter, WithBudget(b, 0, 1))
+	defer reserved.Close()
+	defer greedy.Close()
+
+	reserved.Get("r1")
+	reserved.Get("r2")
+	for _, k := range []string{"g1", "g2", "g3", "g4", "g5"} {
+		greedy.Get(k)
+	}
+	// The reserved group keeps its minimum even though its entries are the least recently used.
+	for _, k := range []string{"r1", "r2"} {
+		if _, ok := reserved.mainCache.get(k); !ok {
+			t.Fatalf("budget evicted key=%s within the minimum of its group", k)
+		}
+	}
+	if b.Used() > b.Limit() {
+		t.Fatalf("budget exceeded (limit: %d, used: %d)", b.Limit(), b.Used())
+	}
+
+	b.SetLimit(6)
+	if b.Used() != 6 || greedy.mainCache.len() != 0 {
+		t.Fatalf("budget failed to shrink (used: %d)", b.Used())
+	}
+}
+
+func TestBudgetWeights(t *testing.T) {
+	getter := GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key[1:]), nil
+	})
+	b := NewBudget(12)
+	heavy := NewGroup("budget_heavy", 0, getter, WithBudget(b, 0, 3))
+	light := NewGroup("budget_light", 0, getter, WithBudget(b, 0, 1))
+	defer heavy.Close()
+	defer light.Close()
+
+	light.Get("l1")
+	heavy.Get("h1")
+	for _, k := range []string{"l2", "l3", "l4", "l5"} {
+		light.Get(k)
+	}
+	if _, ok := heavy.mainCache.get("h1"); !ok {
+		t.Fatalf("budget failed to weight the age of entries")
+	}
+	if _, ok := light.mainCache.get("l2"); ok {
+		t.Fatalf("budget failed to evict key=l2")
+	}
+}
+
+func TestBudgetRejected(t *testing.T) {
+	getter := GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	})
+	b := NewBudget(100)
+	for name, newStore := range map[string]NewStoreFunc{"lru": LRU, "arena": Arena} {
+		g := NewGroup("budget_rejected_"+name, 8, getter, WithStore(newStore), WithBudget(b, 0, 1))
+		defer g.Close()
+		g.mainCache.set("big", ByteView{bytes: make([]byte, 64)}) // rejected by the store
+		if _, ok := g.mainCache.get("big"); ok || b.Used() != 0 {
+			t.Fatalf("%s store: budget counted a rejected entry (used: %d)", name, b.Used())
+		}
+	}
+}
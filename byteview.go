@@ -1,22 +1,151 @@
 package gocache
 
+import (
+	"bytes"
+	"io"
+	"strings"
+	"time"
+	"unsafe"
+)
+
 // A read-only view of bytes stored in the cache.
+// It holds either a byte slice or a string, so that values produced as strings are not copied to bytes.
 type ByteView struct {
-	bytes []byte
+	bytes []byte        // the data if it is held as bytes
+	str   string        // the data if bytes is nil
+	c     Compressor    // (optional) the compressor of bytes; nil means bytes are not compressed
+	cost  time.Duration // (optional) the latency of loading the value from the getter, see GDSF
+	meta  *valueMeta    // (optional) the metadata of the value, see LoadResult
+}
+
+// BytesView returns a view of b, which must not be modified afterwards.
+func BytesView(b []byte) ByteView {
+	return ByteView{bytes: b}
+}
+
+// StringView returns a view of s.
+func StringView(s string) ByteView {
+	return ByteView{str: s}
 }
 
 func (b ByteView) Len() int {
-	return len(b.bytes)
+	if b.bytes != nil {
+		return len(b.bytes)
+	}
+	return len(b.str)
 }
 
 // ByteSlice returns a copy of the data as a byte slice.
 func (b ByteView) ByteSlice() []byte {
-	return copyBytes(b.bytes)
+	if b.bytes != nil {
+		return copyBytes(b.bytes)
+	}
+	return []byte(b.str)
 }
 
-// String returns the data as a string.
+// String returns the data as a string, without copying it if the view holds a string.
 func (b ByteView) String() string {
-	return string(b.bytes)
+	if b.bytes != nil {
+		return string(b.bytes)
+	}
+	return b.str
+}
+
+// At returns the byte at index i.
+func (b ByteView) At(i int) byte {
+	if b.bytes != nil {
+		return b.bytes[i]
+	}
+	return b.str[i]
+}
+
+// Slice returns the view of the data from index from to index to, without copying it.
+func (b ByteView) Slice(from, to int) ByteView {
+	if b.bytes != nil {
+		return ByteView{bytes: b.bytes[from:to]}
+	}
+	return ByteView{str: b.str[from:to]}
+}
+
+// SliceFrom returns the view of the data from index from, without copying it.
+func (b ByteView) SliceFrom(from int) ByteView {
+	if b.bytes != nil {
+		return ByteView{bytes: b.bytes[from:]}
+	}
+	return ByteView{str: b.str[from:]}
+}
+
+// Copy copies the data into dst and returns the number of bytes copied, the lower of len(dst) and b.Len().
+func (b ByteView) Copy(dst []byte) int {
+	if b.bytes != nil {
+		return copy(dst, b.bytes)
+	}
+	return copy(dst, b.str)
+}
+
+// Equal returns whether b and b2 hold the same data.
+func (b ByteView) Equal(b2 ByteView) bool {
+	if b2.bytes != nil {
+		return b.EqualBytes(b2.bytes)
+	}
+	return b.EqualString(b2.str)
+}
+
+// EqualString returns whether b holds the same data as s.
+func (b ByteView) EqualString(s string) bool {
+	if b.bytes != nil {
+		return string(b.bytes) == s
+	}
+	return b.str == s
+}
+
+// EqualBytes returns whether b holds the same data as b2.
+func (b ByteView) EqualBytes(b2 []byte) bool {
+	if b.bytes != nil {
+		return bytes.Equal(b.bytes, b2)
+	}
+	return b.str == string(b2)
+}
+
+// Reader returns an io.ReadSeeker of the data, without copying it.
+func (b ByteView) Reader() io.ReadSeeker {
+	if b.bytes != nil {
+		return bytes.NewReader(b.bytes)
+	}
+	return strings.NewReader(b.str)
+}
+
+// WriteTo writes the data to w, without copying it. It implements io.WriterTo.
+func (b ByteView) WriteTo(w io.Writer) (int64, error) {
+	var n int
+	var err error
+	if b.bytes != nil {
+		n, err = w.Write(b.bytes)
+	} else {
+		n, err = io.WriteString(w, b.str)
+	}
+	return int64(n), err
+}
+
+// data returns the data as a byte slice without copying it, for the internal consumers that only read it,
+// e.g. compressors, encoders and stores copying it elsewhere. The slice must not be modified or retained.
+func (b ByteView) data() []byte {
+	if b.bytes != nil {
+		return b.bytes
+	}
+	return unsafe.Slice(unsafe.StringData(b.str), len(b.str))
+}
+
+// decompress returns the uncompressed view of b.
+func (b ByteView) decompress() (ByteView, error) {
+	if b.c == nil {
+		return b, nil
+	}
+	bytes, err := b.c.Decompress(b.bytes)
+	if err != nil {
+		return ByteView{}, err
+	}
+	return ByteView{bytes: bytes, cost: b.cost, meta: b.meta}, nil
 }
 
 func copyBytes(bytes []byte) []byte {
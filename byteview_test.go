@@ -0,0 +1,65 @@
+package gocache
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestByteView(t *testing.T) {
+	v := ByteView{bytes: []byte("hello world")}
+	if v.At(4) != 'o' || v.Slice(6, 11).String() != "world" || v.SliceFrom(6).String() != "world" {
+		t.Fatalf("view accessors failed")
+	}
+	dst := make([]byte, 5)
+	if n := v.Copy(dst); n != 5 || string(dst) != "hello" {
+		t.Fatalf("view copy failed (expected: hello, got: %s)", dst)
+	}
+	if !v.Equal(ByteView{bytes: []byte("hello world")}) || v.Equal(v.Slice(0, 5)) {
+		t.Fatalf("view equal failed")
+	}
+	if !v.EqualString("hello world") || v.EqualString("hello") || !v.EqualBytes([]byte("hello world")) {
+		t.Fatalf("view equal string failed")
+	}
+
+	r := v.Reader()
+	r.Seek(6, io.SeekStart)
+	if b, err := io.ReadAll(r); err != nil || string(b) != "world" {
+		t.Fatalf("view reader failed (expected: world, got: %s)", b)
+	}
+	var buf bytes.Buffer
+	if n, err := v.WriteTo(&buf); err != nil || n != 11 || buf.String() != "hello world" {
+		t.Fatalf("view write failed (expected: hello world, got: %s)", buf.String())
+	}
+	// The views share the data with the cache.
+	if &v.Slice(6, 11).bytes[0] != &v.bytes[6] {
+		t.Fatalf("view slice copied the data")
+	}
+}
+
+func TestStringView(t *testing.T) {
+	v := StringView("hello world")
+	if v.Len() != 11 || v.At(4) != 'o' || v.Slice(6, 11).String() != "world" || v.SliceFrom(6).String() != "world" {
+		t.Fatalf("string view accessors failed")
+	}
+	if !v.Equal(BytesView([]byte("hello world"))) || !BytesView([]byte("hello world")).Equal(v) || v.Equal(v.Slice(0, 5)) {
+		t.Fatalf("string view equal failed")
+	}
+	if !v.EqualString("hello world") || !v.EqualBytes([]byte("hello world")) || v.EqualBytes([]byte("hello")) {
+		t.Fatalf("string view equal string failed")
+	}
+	dst := make([]byte, 5)
+	if n := v.Copy(dst); n != 5 || string(dst) != "hello" || string(v.ByteSlice()) != "hello world" {
+		t.Fatalf("string view copy failed (expected: hello, got: %s)", dst)
+	}
+	if b, err := io.ReadAll(v.Reader()); err != nil || string(b) != "hello world" {
+		t.Fatalf("string view reader failed (expected: hello world, got: %s)", b)
+	}
+	var buf bytes.Buffer
+	if n, err := v.WriteTo(&buf); err != nil || n != 11 || buf.String() != "hello world" {
+		t.Fatalf("string view write failed (expected: hello world, got: %s)", buf.String())
+	}
+	if string(v.data()) != "hello world" || StringView("").data() != nil {
+		t.Fatalf("string view data failed")
+	}
+}
@@ -1,38 +1,268 @@
 package gocache
 
 import (
+	"hash/maphash"
 	"sync"
 
 	"github.com/thezbm/gocache/lru"
 )
 
-// cache is a thread-safe LRU cache.
+// cache is a thread-safe cache, LRU by default.
+// It is split into shards selected by key hash, each being a Store with its own lock,
+// so that accesses to different shards do not contend. Each shard holds an equal part of the capacity.
 type cache struct {
-	mu      sync.Mutex
-	lru      *lru.Cache
+	mu       sync.Mutex // protects capacity
 	capacity int64
+	nshards  int                                                  // the number of shards; <= 1 means a single shard
+	newStore NewStoreFunc                                         // (optional) creates the store of each shard; nil means LRU
+	onEvict  func(key string, value ByteView, reason EvictReason) // (optional) callback when an entry is evicted
+	budget   *budgetShare                                         // (optional) the shared budget the cache draws from
+	overhead bool                                                 // whether the memory overhead of the entries is counted against the capacity
+
+	once   sync.Once
+	seed   maphash.Seed
+	shards []*shard
+}
+
+// A shard is a part of the cache.
+type shard struct {
+	mu       sync.RWMutex
+	store    Store
+	capacity int64       // the capacity of the shard, including the overhead of its entries
+	overhead int64       // the estimated memory used by the store for each entry besides its key and value
+	removal  EvictReason // the reason reported for the entries removed from the store by remove
+}
+
+// entryOverhead is the estimated memory used by the main cache for each entry in addition to its key and value.
+// It is estimated for the LRU store, and the other stores have a similar overhead unless they are an overheadStore.
+var entryOverhead = lru.EntryOverhead[string, ByteView]()
+
+// WithOverheadAccounting makes the main cache of the group count the estimated memory overhead of each entry
+// against its capacity, on top of len(key) + value.Len(), so that the capacity bounds its actual heap usage.
+// Otherwise, small values can use several times the capacity. See Stats for the estimated usage.
+func WithOverheadAccounting() GroupOption {
+	return func(g *Group) {
+		g.mainCache.overhead = true
+	}
+}
+
+// WithShards splits the main cache of the group into n shards to reduce lock contention.
+// The eviction order is then kept per shard, and each shard holds 1/n of the capacity.
+func WithShards(n int) GroupOption {
+	return func(g *Group) {
+		g.mainCache.nshards = n
+	}
+}
+
+// shard returns the shard of key.
+// The shards are lazy initialized.
+func (c *cache) shard(key string) *shard {
+	c.once.Do(c.init)
+	if len(c.shards) == 1 {
+		return c.shards[0]
+	}
+	return c.shards[maphash.String(c.seed, key)%uint64(len(c.shards))]
+}
+
+func (c *cache) init() {
+	c.seed = maphash.MakeSeed()
+	c.shards = make([]*shard, max(c.nshards, 1))
+	newStore := c.newStore
+	if newStore == nil {
+		newStore = LRU
+	}
+	capacity := c.shardCapacity(c.capacity)
+	for i := range c.shards {
+		s := &shard{capacity: capacity, removal: EvictRemoved}
+		s.store = newStore(capacity, func(key string, value ByteView, reason EvictReason) {
+			if reason == EvictRemoved {
+				reason = s.removal
+			}
+			c.evicted(key, value, reason)
+		})
+		s.overhead = storeOverhead(s.store)
+		c.shards[i] = s
+	}
+}
+
+// shardCapacity returns the capacity of each shard for the given capacity of the cache.
+func (c *cache) shardCapacity(capacity int64) int64 {
+	n := int64(max(c.nshards, 1))
+	return (capacity + n - 1) / n
 }
 
 // set stores a value in the cache with the given key.
-// The LRU cache is lazy initialized.
 func (c *cache) set(key string, value ByteView) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	if c.lru == nil {
-		c.lru = lru.New(c.capacity, nil)
+	s := c.shard(key)
+	s.mu.Lock()
+	if c.budget != nil {
+		c.budget.add(key, int64(len(key))+int64(value.Len()))
+	}
+	s.store.Set(key, value)
+	if c.budget != nil && !holds(s.store, key) {
+		// The entry is added to the budget before Set, so that it is removed if the store evicts it right away.
+		c.budget.remove(key)
+	}
+	if c.overhead {
+		c.resize(s)
+	}
+	s.mu.Unlock()
+	// The budget evicts from other caches, so it is enforced without holding the lock.
+	if c.budget != nil {
+		c.budget.b.enforce()
+	}
+}
+
+// remove removes the entry of key from the cache as an eviction for the given reason.
+func (c *cache) remove(key string, reason EvictReason) {
+	s := c.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.removal = reason
+	s.store.Remove(key)
+	s.removal = EvictRemoved
+}
+
+// removeExpired removes the value of key if it has expired,
+// leaving in place a value set since it was found expired.
+func (c *cache) removeExpired(key string) {
+	s := c.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if v, ok := s.store.Get(key); ok && v.expired() {
+		s.removal = EvictExpired
+		s.store.Remove(key)
+		s.removal = EvictRemoved
+	}
+}
+
+// evicted is called by the stores of the shards when an entry is evicted.
+func (c *cache) evicted(key string, value ByteView, reason EvictReason) {
+	if c.budget != nil && reason != EvictReplaced {
+		c.budget.remove(key)
+	}
+	if c.onEvict != nil {
+		c.onEvict(key, value, reason)
 	}
-	c.lru.Set(key, value)
 }
 
 // get retrieves a value from the cache by its key.
+// The shard is only read locked if its store is a SharedStore.
 func (c *cache) get(key string) (ByteView, bool) {
+	s := c.shard(key)
+	var v ByteView
+	var ok bool
+	if store, shared := s.store.(SharedStore); shared {
+		s.mu.RLock()
+		v, ok = store.GetShared(key)
+		s.mu.RUnlock()
+	} else {
+		s.mu.Lock()
+		v, ok = s.store.Get(key)
+		s.mu.Unlock()
+	}
+	if ok && c.budget != nil {
+		c.budget.touch(key)
+	}
+	return v, ok
+}
+
+// items returns a copy of the cache entries from the first to the last to be evicted, shard by shard.
+func (c *cache) items() []item {
+	c.once.Do(c.init)
+	var items []item
+	for _, s := range c.shards {
+		s.mu.Lock()
+		for k, v := range s.store.Backward() {
+			items = append(items, item{key: k, value: v})
+		}
+		s.mu.Unlock()
+	}
+	return items
+}
+
+// An item is a KV pair taken out of the cache.
+type item struct {
+	key   string
+	value ByteView
+}
+
+// len returns the number of cache entries.
+func (c *cache) len() int {
+	c.once.Do(c.init)
+	var n int
+	for _, s := range c.shards {
+		s.mu.Lock()
+		n += s.store.Len()
+		s.mu.Unlock()
+	}
+	return n
+}
+
+// size returns the size of the cache entries in bytes.
+func (c *cache) size() int64 {
+	c.once.Do(c.init)
+	var size int64
+	for _, s := range c.shards {
+		s.mu.Lock()
+		size += s.store.Size()
+		s.mu.Unlock()
+	}
+	return size
+}
+
+// setCapacity changes the capacity of the cache, evicting entries if it shrinks.
+func (c *cache) setCapacity(capacity int64) {
+	c.once.Do(c.init)
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	if c.lru == nil {
-		return ByteView{}, false
+	c.capacity = capacity
+	for _, s := range c.shards {
+		s.mu.Lock()
+		s.capacity = c.shardCapacity(capacity)
+		c.resize(s)
+		s.mu.Unlock()
+	}
+}
+
+// resize sets the capacity of the store of s to the capacity of s, less the overhead of its entries if it is accounted.
+// The store evicts entries if it is over its new capacity.
+// As every eviction also frees the overhead of an entry, the entries are then evicted one at a time until they fit.
+func (c *cache) resize(s *shard) {
+	if !c.overhead || s.capacity <= 0 {
+		s.store.Resize(s.capacity)
+		return
 	}
-	if v, ok := c.lru.Get(key); ok {
-		return v.(ByteView), ok
+	for s.store.Size()+int64(s.store.Len())*s.overhead > s.capacity {
+		n := s.store.Len()
+		if store, ok := s.store.(evictingStore); ok {
+			store.evictNext()
+		} else {
+			// Shrinking the store below its size evicts its next entry.
+			s.store.Resize(s.store.Size() - 1)
+		}
+		if s.store.Len() == n {
+			break
+		}
 	}
-	return ByteView{}, false
+	s.store.Resize(max(s.capacity-int64(s.store.Len())*s.overhead, 1))
+}
+
+// memory returns the estimated memory used by the cache entries in bytes.
+func (c *cache) memory() int64 {
+	c.once.Do(c.init)
+	var memory int64
+	for _, s := range c.shards {
+		s.mu.RLock()
+		memory += s.store.Size() + int64(s.store.Len())*s.overhead
+		s.mu.RUnlock()
+	}
+	return memory
+}
+
+// getCapacity returns the capacity of the cache.
+func (c *cache) getCapacity() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.capacity
 }
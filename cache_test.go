@@ -0,0 +1,54 @@
+package gocache
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+)
+
+func TestShards(t *testing.T) {
+	c := &cache{capacity: 1 << 10, nshards: 4}
+	for i := range 100 {
+		c.set(strconv.Itoa(i), ByteView{bytes: []byte("value")})
+	}
+	if c.size() > c.capacity {
+		t.Fatalf("sharded cache exceeded its capacity (capacity: %d, size: %d)", c.capacity, c.size())
+	}
+	if v, ok := c.get("99"); !ok || v.String() != "value" {
+		t.Fatalf("sharded cache hit key=99 failed")
+	}
+	for i, s := range c.shards {
+		if s.store.Len() == 0 {
+			t.Fatalf("sharded cache left shard %d empty", i)
+		}
+	}
+
+	c.setCapacity(1 << 8)
+	if c.size() > 1<<8 {
+		t.Fatalf("sharded cache failed to shrink (size: %d)", c.size())
+	}
+}
+
+// BenchmarkCacheGet measures parallel hits, e.g. go test -bench CacheGet -cpu 1,2,4,8.
+func BenchmarkCacheGet(b *testing.B) {
+	keys := make([]string, 1<<12)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	for _, nshards := range []int{1, 16, 64} {
+		b.Run(fmt.Sprintf("shards=%d", nshards), func(b *testing.B) {
+			c := &cache{nshards: nshards}
+			for _, k := range keys {
+				c.set(k, ByteView{bytes: []byte("value")})
+			}
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					c.get(keys[i%len(keys)])
+					i++
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package clock
+
+import (
+	"container/list"
+	"iter"
+	"sync/atomic"
+)
+
+// A CLOCK cache with byte capacity, implemented as the equivalent second-chance FIFO.
+//
+// Entries are kept in insertion order. A hit only sets the visited bit of the entry, and the eviction
+// gives visited entries a second chance by clearing their bit and moving them back to the front.
+// As Get does not reorder the entries, it is safe to call Get concurrently with other calls of Get,
+// but not with the other methods.
+type Cache struct {
+	capacity int64                         // the maximum size of the cache; capacity <= 0 means no limit
+	size     int64                         // the current size of the cache
+	ll       *list.List                    // the entries from the newest at the front to the oldest at the back
+	cache    map[string]*list.Element      // the key to element mapping
+	onEvict  func(key string, value Value) // (optional) callback when an entry is evicted
+}
+
+// The element in the linked list. The KV pair of the cache.
+type entry struct {
+	key     string
+	value   Value
+	visited atomic.Bool // set by hits since the entry was last passed by the eviction
+}
+
+// A Value in the cache implements the Len method to return its size in bytes.
+type Value interface {
+	Len() int // the size in bytes
+}
+
+// The constructor of Cache.
+func New(capacity int64, onEvict func(string, Value)) *Cache {
+	return &Cache{
+		capacity: capacity,
+		ll:       list.New(),
+		cache:    make(map[string]*list.Element),
+		onEvict:  onEvict,
+	}
+}
+
+// Get gets the value from the cache by key.
+func (c *Cache) Get(key string) (Value, bool) {
+	ele, ok := c.cache[key]
+	if !ok {
+		return nil, false
+	}
+	kv := ele.Value.(*entry)
+	if !kv.visited.Load() {
+		kv.visited.Store(true)
+	}
+	return kv.value, true
+}
+
+// Peek gets the value of key from the cache without updating its visited bit.
+func (c *Cache) Peek(key string) (Value, bool) {
+	ele, ok := c.cache[key]
+	if !ok {
+		return nil, false
+	}
+	return ele.Value.(*entry).value, true
+}
+
+// Set sets a value with a key in the cache.
+//
+// An entry larger than the capacity is rejected rather than evicting the whole cache,
+// and the previous value of key, if any, is removed.
+func (c *Cache) Set(key string, value Value) {
+	if c.capacity > 0 && int64(len(key))+int64(value.Len()) > c.capacity {
+		c.Remove(key)
+		return
+	}
+	if ele, ok := c.cache[key]; ok {
+		kv := ele.Value.(*entry)
+		c.size += int64(value.Len()) - int64(kv.value.Len())
+		kv.value = value
+		kv.visited.Store(true)
+	} else {
+		c.cache[key] = c.ll.PushFront(&entry{key: key, value: value})
+		c.size += int64(len(key)) + int64(value.Len())
+	}
+	c.evict()
+}
+
+// Remove removes the entry of key from the cache.
+// The onEvict callback is called for the removed entry.
+func (c *Cache) Remove(key string) {
+	if ele, ok := c.cache[key]; ok {
+		c.removeElement(ele)
+	}
+}
+
+// Len returns the number of cache entries.
+func (c *Cache) Len() int {
+	return c.ll.Len()
+}
+
+// Size returns the size of the cache entries in bytes.
+func (c *Cache) Size() int64 {
+	return c.size
+}
+
+// Resize changes the capacity of the cache, evicting entries if it shrinks.
+func (c *Cache) Resize(capacity int64) {
+	c.capacity = capacity
+	c.evict()
+}
+
+// Backward returns an iterator over the cache entries from the oldest to the newest.
+// Visited entries get a second chance, so this is only the eviction order if none of them is visited.
+func (c *Cache) Backward() iter.Seq2[string, Value] {
+	return func(yield func(string, Value) bool) {
+		for ele := c.ll.Back(); ele != nil; ele = ele.Prev() {
+			kv := ele.Value.(*entry)
+			if !yield(kv.key, kv.value) {
+				return
+			}
+		}
+	}
+}
+
+// evict evicts the oldest entries not visited until the cache fits its capacity.
+func (c *Cache) evict() {
+	for c.capacity > 0 && c.size > c.capacity {
+		ele := c.ll.Back()
+		if kv := ele.Value.(*entry); kv.visited.Load() {
+			kv.visited.Store(false)
+			c.ll.MoveToFront(ele)
+			continue
+		}
+		c.removeElement(ele)
+	}
+}
+
+func (c *Cache) removeElement(ele *list.Element) {
+	kv := ele.Value.(*entry)
+	c.ll.Remove(ele)
+	delete(c.cache, kv.key)
+	c.size -= int64(len(kv.key)) + int64(kv.value.Len())
+	if c.onEvict != nil {
+		c.onEvict(kv.key, kv.value)
+	}
+}
@@ -0,0 +1,87 @@
+package clock
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+type value string
+
+func (v value) Len() int {
+	return len(v)
+}
+
+func TestGet(t *testing.T) {
+	c := New(int64(0), nil)
+	c.Set("k1", value("v1"))
+	if v, ok := c.Get("k1"); !ok || string(v.(value)) != "v1" {
+		t.Fatalf("cache hit k1=v1 failed")
+	}
+	if _, ok := c.Get("k2"); ok {
+		t.Fatalf("cache miss k2 failed")
+	}
+	c.Set("k1", value("value1"))
+	if c.Size() != int64(len("k1"+"value1")) || c.Len() != 1 {
+		t.Fatalf("cache set failed (expected: %v, got: %v)", len("k1"+"value1"), c.Size())
+	}
+}
+
+func TestSecondChance(t *testing.T) {
+	keys := []string{}
+	c := New(int64(len("k1"+"v1")*3), func(key string, value Value) {
+		keys = append(keys, key)
+	})
+	c.Set("k1", value("v1"))
+	c.Set("k2", value("v2"))
+	c.Set("k3", value("v3"))
+	c.Get("k1")
+	c.Set("k4", value("v4"))
+	c.Set("k5", value("v5"))
+	if expected := []string{"k2", "k3"}; !reflect.DeepEqual(expected, keys) {
+		t.Fatalf("cache eviction failed (expected: %v, got: %v)", expected, keys)
+	}
+	// k1 lost its visited bit when it got its second chance.
+	c.Set("k6", value("v6"))
+	c.Set("k7", value("v7"))
+	if expected := []string{"k2", "k3", "k4", "k1"}; !reflect.DeepEqual(expected, keys) {
+		t.Fatalf("cache eviction failed (expected: %v, got: %v)", expected, keys)
+	}
+}
+
+func TestConcurrentGet(t *testing.T) {
+	c := New(int64(0), nil)
+	for i := range 100 {
+		c.Set(fmt.Sprintf("k%d", i), value("v"))
+	}
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 100 {
+				if _, ok := c.Get(fmt.Sprintf("k%d", i)); !ok {
+					t.Errorf("cache hit k%d failed", i)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestRemove(t *testing.T) {
+	keys := []string{}
+	c := New(int64(0), func(key string, value Value) {
+		keys = append(keys, key)
+	})
+	c.Set("k1", value("v1"))
+	c.Remove("k1")
+	c.Remove("k2")
+	if _, ok := c.Get("k1"); ok || c.Len() != 0 || c.Size() != 0 {
+		t.Fatalf("cache remove k1 failed")
+	}
+	if expected := []string{"k1"}; !reflect.DeepEqual(expected, keys) {
+		t.Fatalf("cache remove callback failed (expected: %v, got: %v)", expected, keys)
+	}
+}
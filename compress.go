@@ -0,0 +1,95 @@
+package gocache
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+)
+
+// A Compressor compresses the values stored in the cache.
+// The name identifies the encoding when compressed values are exchanged between peers.
+type Compressor interface {
+	Name() string
+	Compress(p []byte) ([]byte, error)
+	Decompress(p []byte) ([]byte, error)
+}
+
+// WithCompressor makes the group store its values compressed by c.
+// The capacity of the cache is then accounted on the compressed sizes.
+// Values that do not shrink are stored as they are.
+func WithCompressor(c Compressor) GroupOption {
+	return func(g *Group) {
+		g.compressor = c
+	}
+}
+
+// Gzip returns a Compressor using gzip at the given level, e.g. gzip.DefaultCompression.
+func Gzip(level int) Compressor {
+	return gzipCompressor{level: level}
+}
+
+// Flate returns a Compressor using DEFLATE at the given level, e.g. flate.DefaultCompression.
+func Flate(level int) Compressor {
+	return flateCompressor{level: level}
+}
+
+type gzipCompressor struct {
+	level int
+}
+
+func (c gzipCompressor) Name() string {
+	return "gzip"
+}
+
+func (c gzipCompressor) Compress(p []byte) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	w, err := gzip.NewWriterLevel(buf, c.level)
+	if err != nil {
+		return nil, err
+	}
+	return finish(buf, w, p)
+}
+
+func (c gzipCompressor) Decompress(p []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(p))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+type flateCompressor struct {
+	level int
+}
+
+func (c flateCompressor) Name() string {
+	return "flate"
+}
+
+func (c flateCompressor) Compress(p []byte) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	w, err := flate.NewWriter(buf, c.level)
+	if err != nil {
+		return nil, err
+	}
+	return finish(buf, w, p)
+}
+
+func (c flateCompressor) Decompress(p []byte) ([]byte, error) {
+	r := flate.NewReader(bytes.NewReader(p))
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// finish writes p to the compressing writer w and returns what ends up in buf.
+func finish(buf *bytes.Buffer, w io.WriteCloser, p []byte) ([]byte, error) {
+	if _, err := w.Write(p); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
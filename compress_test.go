@@ -0,0 +1,91 @@
+package gocache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"testing"
+
+	pb "github.com/thezbm/gocache/gocachepb"
+)
+
+func TestCompressors(t *testing.T) {
+	data := bytes.Repeat([]byte("gocache"), 100)
+	for _, c := range []Compressor{Gzip(gzip.DefaultCompression), Flate(gzip.BestSpeed)} {
+		compressed, err := c.Compress(data)
+		if err != nil || len(compressed) >= len(data) {
+			t.Fatalf("%s compress failed (err: %v, size: %d)", c.Name(), err, len(compressed))
+		}
+		if p, err := c.Decompress(compressed); err != nil || !bytes.Equal(p, data) {
+			t.Fatalf("%s decompress failed (err: %v)", c.Name(), err)
+		}
+	}
+}
+
+func TestWithCompressor(t *testing.T) {
+	data := bytes.Repeat([]byte("gocache"), 100)
+	g := NewGroup("compressed", 0, GetterFunc(
+		func(key string) ([]byte, error) {
+			return data, nil
+		}), WithCompressor(Gzip(gzip.DefaultCompression)))
+
+	if v, err := g.Get("key"); err != nil || !bytes.Equal(v.ByteSlice(), data) {
+		t.Fatalf("cache Get failed with compressor (err: %v)", err)
+	}
+	if v, ok := g.mainCache.get("key"); !ok || v.c == nil || v.Len() >= len(data) {
+		t.Fatalf("cache failed to store the value compressed")
+	}
+	if v, err := g.Get("key"); err != nil || !bytes.Equal(v.ByteSlice(), data) || v.cost == 0 {
+		t.Fatalf("cache hit failed with compressor (err: %v, cost: %v)", err, v.cost)
+	}
+}
+
+// localPeer is a Peer serving a group of this process.
+type localPeer struct {
+	g *Group
+}
+
+func (p localPeer) Get(in *pb.Request, out *pb.Response) error {
+	resp, err := p.g.response(in.GetKey(), in.GetAcceptEncoding())
+	if err != nil {
+		return err
+	}
+	out.Value, out.Encoding = resp.Value, resp.Encoding
+	return nil
+}
+
+func TestCompressedPeerTransfer(t *testing.T) {
+	data := bytes.Repeat([]byte("gocache"), 100)
+	owner := NewGroup("compressed_owner", 0, GetterFunc(
+		func(key string) ([]byte, error) {
+			return data, nil
+		}), WithCompressor(Gzip(gzip.DefaultCompression)))
+	owner.Get("key")
+
+	for _, c := range []Compressor{nil, Gzip(gzip.BestSpeed), Flate(gzip.BestSpeed)} {
+		resp := &pb.Response{}
+		req := &pb.Request{Key: "key"}
+		if c != nil {
+			req.AcceptEncoding = c.Name()
+		}
+		localPeer{owner}.Get(req, resp)
+		if c != nil && c.Name() == "gzip" {
+			if resp.Encoding != "gzip" {
+				t.Fatalf("peer failed to send the value compressed (got encoding: %q)", resp.Encoding)
+			}
+			continue
+		}
+		if resp.Encoding != "" || !bytes.Equal(resp.Value, data) {
+			t.Fatalf("peer failed to send the value uncompressed to %v", req.AcceptEncoding)
+		}
+	}
+
+	g := NewGroup("compressed_requester", 0, GetterFunc(
+		func(key string) ([]byte, error) {
+			return nil, nil
+		}), WithCompressor(Gzip(gzip.BestSpeed)))
+	if v, err := g.getFromPeer(localPeer{owner}, "key"); err != nil || v.c == nil {
+		t.Fatalf("getFromPeer failed to keep the value compressed (err: %v)", err)
+	} else if v, _ = v.decompress(); !bytes.Equal(v.bytes, data) {
+		t.Fatalf("getFromPeer returned a corrupted value")
+	}
+}
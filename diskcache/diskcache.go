@@ -0,0 +1,371 @@
+package diskcache
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+)
+
+const (
+	defaultSegmentSize  = 16 << 20 // the size at which the active segment is rotated
+	defaultCompactRatio = 0.5      // a sealed segment with less live data than this ratio is compacted
+
+	segmentExt = ".seg"
+
+	// A record is: crc uint32 | flag byte | key length uint32 | value length uint32 | key | value.
+	// The CRC-32 (IEEE) covers everything after itself.
+	recordHeaderSize = 13
+	flagSet          = 0
+	flagRemove       = 1
+)
+
+// ErrClosed is returned when writing to a closed cache.
+var ErrClosed = errors.New("diskcache: cache is closed")
+
+var errBadRecord = errors.New("bad record")
+
+// A disk cache made of segmented append-only files.
+// Only the index of the entries is kept in memory.
+type Cache struct {
+	mu           sync.Mutex
+	dir          string              // the directory of the segment files
+	capacity     int64               // the maximum size of the segment files; capacity <= 0 means no limit
+	size         int64               // the current size of the segment files
+	segmentSize  int64               // the size at which the active segment is rotated
+	compactRatio float64             // the live data ratio under which a sealed segment is compacted
+	segments     []*segment          // sorted by id; the last one is active
+	index        map[string]location // the key to record mapping
+}
+
+// A segment is a single append-only file.
+type segment struct {
+	id   uint64
+	f    *os.File
+	size int64 // the size of the file
+	live int64 // the size of the records still referenced by the index
+}
+
+// A location points to a set record.
+type location struct {
+	seg    *segment
+	offset int64
+	size   int64 // the size of the whole record
+}
+
+// Open opens the disk cache in dir, creating the directory if needed.
+// The index is rebuilt from the existing segment files.
+func Open(dir string, capacity int64) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	c := &Cache{
+		dir:          dir,
+		capacity:     capacity,
+		segmentSize:  defaultSegmentSize,
+		compactRatio: defaultCompactRatio,
+		index:        make(map[string]location),
+	}
+	if capacity > 0 {
+		// Keep a few segments around so that dropping the oldest one does not empty the cache.
+		c.segmentSize = min(c.segmentSize, max(capacity/4, 1))
+	}
+	names, err := filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		var id uint64
+		if _, err := fmt.Sscanf(filepath.Base(name), "%016x"+segmentExt, &id); err != nil {
+			continue
+		}
+		f, err := os.OpenFile(name, os.O_RDWR, 0o644)
+		if err != nil {
+			c.Close()
+			return nil, err
+		}
+		seg := &segment{id: id, f: f}
+		c.segments = append(c.segments, seg)
+		if err := c.load(seg); err != nil {
+			c.Close()
+			return nil, err
+		}
+		c.size += seg.size
+	}
+	if len(c.segments) == 0 {
+		if err := c.rotate(); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// load scans the records of seg into the index.
+// A torn record at the end of the segment, left by a crash, is truncated.
+func (c *Cache) load(seg *segment) error {
+	fi, err := seg.f.Stat()
+	if err != nil {
+		return err
+	}
+	var offset int64
+	for {
+		flag, key, _, size, err := readRecord(seg.f, offset, fi.Size(), false)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Printf("[diskcache] truncating %s at %d: %v", seg.f.Name(), offset, err)
+			if err := seg.f.Truncate(offset); err != nil {
+				return err
+			}
+			break
+		}
+		c.unindex(key)
+		if flag == flagSet {
+			c.index[key] = location{seg: seg, offset: offset, size: size}
+			seg.live += size
+		}
+		offset += size
+	}
+	seg.size = offset
+	return nil
+}
+
+// Get gets the value from the cache by key.
+func (c *Cache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	loc, ok := c.index[key]
+	if !ok {
+		return nil, false
+	}
+	_, _, value, _, err := readRecord(loc.seg.f, loc.offset, loc.seg.size, true)
+	if err != nil {
+		log.Printf("[diskcache] failed to read key=%s: %v", key, err)
+		c.unindex(key)
+		return nil, false
+	}
+	return value, true
+}
+
+// Set sets a value with a key in the cache.
+func (c *Cache) Set(key string, value []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.segments == nil {
+		return ErrClosed
+	}
+	if err := c.append(flagSet, key, value); err != nil {
+		return err
+	}
+	return c.maintain()
+}
+
+// Remove removes the value of key from the cache.
+func (c *Cache) Remove(key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.segments == nil {
+		return ErrClosed
+	}
+	if _, ok := c.index[key]; !ok {
+		return nil
+	}
+	if err := c.append(flagRemove, key, nil); err != nil {
+		return err
+	}
+	return c.maintain()
+}
+
+// Compact rewrites the live records of the sealed segments into the active one,
+// reclaiming the space of overwritten and removed entries.
+func (c *Cache) Compact() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.segments == nil {
+		return ErrClosed
+	}
+	return c.compact(1)
+}
+
+// Len returns the number of cache entries.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.index)
+}
+
+// Size returns the size of the segment files in bytes.
+func (c *Cache) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size
+}
+
+// Close closes the segment files.
+func (c *Cache) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var errs []error
+	for _, seg := range c.segments {
+		errs = append(errs, seg.f.Close())
+	}
+	c.segments = nil
+	c.index = make(map[string]location)
+	return errors.Join(errs...)
+}
+
+// append appends a record to the active segment and updates the index.
+func (c *Cache) append(flag byte, key string, value []byte) error {
+	seg := c.segments[len(c.segments)-1]
+	rec := make([]byte, recordHeaderSize+len(key)+len(value))
+	rec[4] = flag
+	binary.BigEndian.PutUint32(rec[5:], uint32(len(key)))
+	binary.BigEndian.PutUint32(rec[9:], uint32(len(value)))
+	copy(rec[recordHeaderSize:], key)
+	copy(rec[recordHeaderSize+len(key):], value)
+	binary.BigEndian.PutUint32(rec, crc32.ChecksumIEEE(rec[4:]))
+	if _, err := seg.f.WriteAt(rec, seg.size); err != nil {
+		return err
+	}
+
+	loc := location{seg: seg, offset: seg.size, size: int64(len(rec))}
+	seg.size += loc.size
+	c.size += loc.size
+	c.unindex(key)
+	if flag == flagSet {
+		c.index[key] = loc
+		seg.live += loc.size
+	}
+	return nil
+}
+
+// unindex removes key from the index.
+func (c *Cache) unindex(key string) {
+	if loc, ok := c.index[key]; ok {
+		loc.seg.live -= loc.size
+		delete(c.index, key)
+	}
+}
+
+// maintain rotates the active segment when it is full, compacts sealed segments with too much garbage,
+// and drops the oldest segments while the cache is over capacity.
+func (c *Cache) maintain() error {
+	if c.segments[len(c.segments)-1].size < c.segmentSize {
+		return nil
+	}
+	if err := c.rotate(); err != nil {
+		return err
+	}
+	if err := c.compact(c.compactRatio); err != nil {
+		return err
+	}
+	for c.capacity > 0 && c.size > c.capacity && len(c.segments) > 1 {
+		if err := c.drop(c.segments[0]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rotate seals the active segment and starts a new one.
+func (c *Cache) rotate() error {
+	var id uint64
+	if len(c.segments) > 0 {
+		id = c.segments[len(c.segments)-1].id + 1
+	}
+	name := filepath.Join(c.dir, fmt.Sprintf("%016x"+segmentExt, id))
+	f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	c.segments = append(c.segments, &segment{id: id, f: f})
+	return nil
+}
+
+// compact moves the live records of the sealed segments whose live data ratio is under ratio
+// to the active segment and drops them.
+// Segments are compacted from the oldest one and the first segment over ratio stops the compaction,
+// so that no older record is left behind to be resurrected by dropping a removal record.
+func (c *Cache) compact(ratio float64) error {
+	sealed := c.segments[:len(c.segments)-1]
+	for _, seg := range slices.Clone(sealed) {
+		if seg.size > 0 && float64(seg.live) >= float64(seg.size)*ratio {
+			break
+		}
+		for key, loc := range c.index {
+			if loc.seg != seg {
+				continue
+			}
+			_, _, value, _, err := readRecord(seg.f, loc.offset, seg.size, true)
+			if err != nil {
+				return err
+			}
+			if err := c.append(flagSet, key, value); err != nil {
+				return err
+			}
+		}
+		if err := c.drop(seg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// drop deletes seg together with the entries it holds.
+func (c *Cache) drop(seg *segment) error {
+	for key, loc := range c.index {
+		if loc.seg == seg {
+			c.unindex(key)
+		}
+	}
+	c.segments = slices.DeleteFunc(c.segments, func(s *segment) bool { return s == seg })
+	c.size -= seg.size
+	seg.f.Close()
+	return os.Remove(seg.f.Name())
+}
+
+// readRecord reads the record at offset of f, which holds records up to end,
+// and returns its flag, key, (optionally) value and size.
+func readRecord(f *os.File, offset, end int64, withValue bool) (flag byte, key string, value []byte, size int64, err error) {
+	header := make([]byte, recordHeaderSize)
+	if _, err = f.ReadAt(header, offset); err != nil {
+		if err == io.EOF && atEnd(f, offset) {
+			return 0, "", nil, 0, io.EOF
+		}
+		return 0, "", nil, 0, fmt.Errorf("%w: reading header: %v", errBadRecord, err)
+	}
+	flag = header[4]
+	keyLen := int64(binary.BigEndian.Uint32(header[5:]))
+	valueLen := int64(binary.BigEndian.Uint32(header[9:]))
+	// The lengths of a corrupted header must not cause a huge allocation.
+	if offset+recordHeaderSize+keyLen+valueLen > end {
+		return 0, "", nil, 0, fmt.Errorf("%w: record of %d bytes beyond the end of the segment", errBadRecord, keyLen+valueLen)
+	}
+	body := make([]byte, keyLen+valueLen)
+	if _, err = f.ReadAt(body, offset+recordHeaderSize); err != nil {
+		return 0, "", nil, 0, fmt.Errorf("%w: reading body: %v", errBadRecord, err)
+	}
+	crc := crc32.Update(crc32.ChecksumIEEE(header[4:]), crc32.IEEETable, body)
+	if crc != binary.BigEndian.Uint32(header) {
+		return 0, "", nil, 0, fmt.Errorf("%w: checksum mismatch", errBadRecord)
+	}
+	key = string(body[:keyLen])
+	if withValue {
+		value = body[keyLen:]
+	}
+	return flag, key, value, recordHeaderSize + keyLen + valueLen, nil
+}
+
+// atEnd reports whether f ends exactly at offset.
+func atEnd(f *os.File, offset int64) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Size() == offset
+}
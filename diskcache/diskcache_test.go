@@ -0,0 +1,112 @@
+package diskcache
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestGetSet(t *testing.T) {
+	c, err := Open(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("open failed: %v", err)
+	}
+	defer c.Close()
+	c.Set("k1", []byte("v1"))
+	if v, ok := c.Get("k1"); !ok || string(v) != "v1" {
+		t.Fatalf("cache hit k1=v1 failed")
+	}
+	c.Set("k1", []byte("value1"))
+	if v, ok := c.Get("k1"); !ok || string(v) != "value1" {
+		t.Fatalf("cache overwrite k1=value1 failed")
+	}
+	if _, ok := c.Get("k2"); ok {
+		t.Fatalf("cache miss k2 failed")
+	}
+	c.Remove("k1")
+	if _, ok := c.Get("k1"); ok || c.Len() != 0 {
+		t.Fatalf("cache remove k1 failed")
+	}
+}
+
+func TestReopen(t *testing.T) {
+	dir := t.TempDir()
+	c, _ := Open(dir, 0)
+	c.Set("k1", []byte("v1"))
+	c.Set("k2", []byte("v2"))
+	c.Set("k1", []byte("value1"))
+	c.Remove("k2")
+	c.Close()
+
+	c, err := Open(dir, 0)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer c.Close()
+	if v, ok := c.Get("k1"); !ok || string(v) != "value1" || c.Len() != 1 {
+		t.Fatalf("reopen failed to rebuild the index")
+	}
+}
+
+func TestCapacity(t *testing.T) {
+	c, _ := Open(t.TempDir(), 1<<10)
+	defer c.Close()
+	for i := range 100 {
+		c.Set(fmt.Sprintf("key%d", i), make([]byte, 64))
+	}
+	if c.Size() > 1<<10+c.segmentSize {
+		t.Fatalf("cache capacity exceeded (capacity: %d, size: %d)", 1<<10, c.Size())
+	}
+	if _, ok := c.Get("key0"); ok {
+		t.Fatalf("cache failed to drop the oldest segment")
+	}
+	if _, ok := c.Get("key99"); !ok {
+		t.Fatalf("cache dropped the newest entry")
+	}
+}
+
+func TestCompact(t *testing.T) {
+	c, _ := Open(t.TempDir(), 0)
+	defer c.Close()
+	c.segmentSize = 64
+	for range 10 {
+		c.Set("key", make([]byte, 32))
+	}
+	c.Set("other", []byte("value"))
+	before := c.Size()
+	if err := c.Compact(); err != nil {
+		t.Fatalf("compact failed: %v", err)
+	}
+	if c.Size() >= before {
+		t.Fatalf("compact failed to reclaim space (before: %d, after: %d)", before, c.Size())
+	}
+	if _, ok := c.Get("key"); !ok || c.Len() != 2 {
+		t.Fatalf("compact lost entries")
+	}
+}
+
+func TestCorruptedLength(t *testing.T) {
+	dir := t.TempDir()
+	c, _ := Open(dir, 0)
+	c.Set("k1", []byte("v1"))
+	c.Set("k2", []byte("v2"))
+	name := c.segments[0].f.Name()
+	c.Close()
+
+	// The value length of the second record claims 4 GiB.
+	f, err := os.OpenFile(name, os.O_RDWR, 0o644)
+	if err != nil {
+		t.Fatalf("open segment failed: %v", err)
+	}
+	f.WriteAt([]byte{0xff, 0xff, 0xff, 0xff}, int64(recordHeaderSize+len("k1"+"v1"))+9)
+	f.Close()
+
+	c, err = Open(dir, 0)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer c.Close()
+	if _, ok := c.Get("k1"); !ok || c.Len() != 1 {
+		t.Fatalf("reopen failed to truncate the corrupted record (len: %v)", c.Len())
+	}
+}
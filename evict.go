@@ -0,0 +1,76 @@
+package gocache
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/thezbm/gocache/lru"
+)
+
+// An EvictReason tells why an entry left the main cache of a group.
+type EvictReason int
+
+const (
+	EvictCapacity EvictReason = iota // evicted to respect the capacity of the group or its budget
+	EvictExpired                     // removed because it expired
+	EvictRemoved                     // removed explicitly
+	EvictReplaced                    // replaced by a new value; the key stays in the cache
+	EvictCleared                     // removed by clearing the cache; a group never clears its main cache, but a Store may
+	EvictPinned                      // moved to the pinned entries by Pin; the key stays in the group
+)
+
+func (r EvictReason) String() string {
+	switch r {
+	case EvictCapacity:
+		return "capacity"
+	case EvictExpired:
+		return "expired"
+	case EvictRemoved:
+		return "removed"
+	case EvictReplaced:
+		return "replaced"
+	case EvictCleared:
+		return "cleared"
+	case EvictPinned:
+		return "pinned"
+	}
+	return fmt.Sprintf("EvictReason(%d)", int(r))
+}
+
+// An EvictHook is called when an entry leaves the main cache of a group, with the uncompressed value.
+// It is called while the shard of the entry is locked, so it must not access the group.
+type EvictHook func(key string, value ByteView, reason EvictReason)
+
+// WithEvictHook adds a hook called for the entries leaving the main cache of the group,
+// e.g. to count the churn or to spill the evicted data elsewhere.
+// The hooks are called in the order they were added.
+func WithEvictHook(hook EvictHook) GroupOption {
+	return func(g *Group) {
+		g.evictHooks = append(g.evictHooks, hook)
+	}
+}
+
+// evicted is called when an entry leaves the main cache.
+func (g *Group) evicted(key string, value ByteView, reason EvictReason) {
+	value, err := value.decompress()
+	if err != nil {
+		log.Printf("[gocache] failed to decompress evicted key=%s: %v", key, err)
+		return
+	}
+	for _, hook := range g.evictHooks {
+		hook(key, value, reason)
+	}
+}
+
+// lruEvictReason converts the reason of an eviction from an lru.GCache.
+func lruEvictReason(reason lru.EvictReason) EvictReason {
+	switch reason {
+	case lru.EvictRemoved:
+		return EvictRemoved
+	case lru.EvictReplaced:
+		return EvictReplaced
+	case lru.EvictCleared:
+		return EvictCleared
+	}
+	return EvictCapacity
+}
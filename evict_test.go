@@ -0,0 +1,72 @@
+package gocache
+
+import (
+	"compress/gzip"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEvictHook(t *testing.T) {
+	evicted := []string{}
+	hook := func(key string, value ByteView, reason EvictReason) {
+		evicted = append(evicted, key+"="+value.String()+":"+reason.String())
+	}
+	// Every entry is 2 + 10 bytes, e.g. key=k1 and value=k1k1k1k1k1.
+	g := NewGroup("evict_hook", 24, GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte(strings.Repeat(key, 5)), nil
+		}), WithEvictHook(hook), WithCompressor(Gzip(gzip.BestSpeed)))
+	defer g.Close()
+
+	g.Get("k1")
+	g.Get("k2")
+	g.Get("k3") // evicts k1
+	g.mainCache.remove("k2", EvictRemoved)
+	if expected := []string{"k1=k1k1k1k1k1:capacity", "k2=k2k2k2k2k2:removed"}; !reflect.DeepEqual(expected, evicted) {
+		t.Fatalf("group eviction hook failed (expected: %v, got: %v)", expected, evicted)
+	}
+}
+
+func TestEvictHookReasons(t *testing.T) {
+	for name, newStore := range map[string]NewStoreFunc{"LRU": LRU, "TinyLFU": TinyLFU, "S3FIFO": S3FIFO} {
+		reasons := map[string]EvictReason{}
+		c := &cache{newStore: newStore, onEvict: func(key string, value ByteView, reason EvictReason) {
+			reasons[key] = reason
+		}}
+		c.set("k1", ByteView{bytes: []byte("v1")})
+		c.set("k2", ByteView{bytes: []byte("v2")})
+		c.remove("k1", EvictRemoved)
+		c.remove("k2", EvictCapacity)
+		if expected := map[string]EvictReason{"k1": EvictRemoved, "k2": EvictCapacity}; !reflect.DeepEqual(expected, reasons) {
+			t.Fatalf("%v store eviction reasons failed (expected: %v, got: %v)", name, expected, reasons)
+		}
+	}
+}
+
+func TestEvictHookStores(t *testing.T) {
+	// Every store reports the same reasons for the same operations.
+	type event struct {
+		key, value string
+		reason     EvictReason
+	}
+	stores := map[string]NewStoreFunc{
+		"LRU": LRU, "TinyLFU": TinyLFU, "ARC": ARC, "TwoQ": TwoQ,
+		"CLOCK": CLOCK, "S3FIFO": S3FIFO, "GDSF": GDSF, "Arena": Arena,
+	}
+	for name, newStore := range stores {
+		var events []event
+		c := &cache{capacity: 1 << 10, newStore: newStore, onEvict: func(key string, value ByteView, reason EvictReason) {
+			events = append(events, event{key, value.String(), reason})
+		}}
+		c.set("k1", StringView("v1"))
+		c.set("k1", StringView("value1"))
+		c.set("k2", StringView("v2"))
+		c.remove("k2", EvictRemoved)
+		c.setCapacity(1)
+		expected := []event{{"k1", "v1", EvictReplaced}, {"k2", "v2", EvictRemoved}, {"k1", "value1", EvictCapacity}}
+		if !reflect.DeepEqual(expected, events) {
+			t.Fatalf("%v store eviction reasons failed (expected: %v, got: %v)", name, expected, events)
+		}
+	}
+}
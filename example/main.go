@@ -21,17 +21,7 @@ func startCacheServer(addr string, urls []string, group *gocache.Group) {
 
 // startAPIServer starts a simple API server for the cache at addr.
 func startAPIServer(addr string, group *gocache.Group) {
-	http.Handle("/api", http.HandlerFunc(
-		func(w http.ResponseWriter, r *http.Request) {
-			key := r.URL.Query().Get("key")
-			view, err := group.Get(key)
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-			w.Header().Set("Content-Type", "application/octet-stream")
-			w.Write(view.ByteSlice())
-		}))
+	http.Handle("/api", gocache.GroupHandler(group))
 	log.Println("gocache API server is running at:", addr)
 	http.ListenAndServe(addr[7:], nil)
 }
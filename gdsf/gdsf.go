@@ -0,0 +1,193 @@
+package gdsf
+
+import (
+	"cmp"
+	"container/heap"
+	"iter"
+	"slices"
+)
+
+// A GDSF (GreedyDual-Size-Frequency) cache with byte capacity.
+//
+// Every entry has a priority of L + frequency * cost / size, and the entry of the lowest priority is evicted first.
+// L is the priority of the last evicted entry, so that the entries not accessed for a long time age
+// relatively to the new ones. Entries that are frequently accessed, costly to load again, or small are kept longer.
+type Cache struct {
+	capacity int64                         // the maximum size of the cache; capacity <= 0 means no limit
+	size     int64                         // the current size of the cache
+	inflate  float64                       // L, the priority of the last evicted entry
+	pq       queue                         // the entries by priority
+	cache    map[string]*entry             // the key to entry mapping
+	onEvict  func(key string, value Value) // (optional) callback when an entry is evicted
+}
+
+// An entry of the cache in the priority queue.
+type entry struct {
+	key      string
+	value    Value
+	cost     float64
+	freq     float64
+	priority float64
+	index    int // the index in the priority queue
+}
+
+// A Value in the cache implements the Len method to return its size in bytes.
+type Value interface {
+	Len() int // the size in bytes
+}
+
+// The constructor of Cache.
+func New(capacity int64, onEvict func(string, Value)) *Cache {
+	return &Cache{
+		capacity: capacity,
+		cache:    make(map[string]*entry),
+		onEvict:  onEvict,
+	}
+}
+
+// Get gets the value from the cache by key.
+func (c *Cache) Get(key string) (Value, bool) {
+	e, ok := c.cache[key]
+	if !ok {
+		return nil, false
+	}
+	e.freq++
+	c.prioritize(e)
+	heap.Fix(&c.pq, e.index)
+	return e.value, true
+}
+
+// Peek gets the value of key from the cache without updating its frequency.
+func (c *Cache) Peek(key string) (Value, bool) {
+	e, ok := c.cache[key]
+	if !ok {
+		return nil, false
+	}
+	return e.value, true
+}
+
+// Set sets a value with a key in the cache, with a cost of 1.
+// The costs have no unit of their own, so a cache should either use Set only or SetWithCost only, in one unit.
+func (c *Cache) Set(key string, value Value) {
+	c.SetWithCost(key, value, 1)
+}
+
+// SetWithCost sets a value with a key in the cache, with the cost of loading it again, e.g. its load latency.
+// Costs <= 0 are counted as the smallest positive cost.
+func (c *Cache) SetWithCost(key string, value Value, cost float64) {
+	if e, ok := c.cache[key]; ok {
+		c.size += int64(value.Len()) - int64(e.value.Len())
+		e.value, e.cost = value, cost
+		e.freq++
+		c.prioritize(e)
+		heap.Fix(&c.pq, e.index)
+	} else {
+		e := &entry{key: key, value: value, cost: cost, freq: 1}
+		c.cache[key] = e
+		c.size += size(e)
+		c.prioritize(e)
+		heap.Push(&c.pq, e)
+	}
+	c.evict()
+}
+
+// Remove removes the entry of key from the cache.
+// The onEvict callback is called for the removed entry.
+func (c *Cache) Remove(key string) {
+	if e, ok := c.cache[key]; ok {
+		heap.Remove(&c.pq, e.index)
+		c.removeEntry(e)
+	}
+}
+
+// Len returns the number of cache entries.
+func (c *Cache) Len() int {
+	return len(c.cache)
+}
+
+// Size returns the size of the cache entries in bytes.
+func (c *Cache) Size() int64 {
+	return c.size
+}
+
+// Resize changes the capacity of the cache, evicting entries if it shrinks.
+func (c *Cache) Resize(capacity int64) {
+	c.capacity = capacity
+	c.evict()
+}
+
+// Backward returns an iterator over the cache entries from the lowest to the highest priority.
+// It does not update the priority of the entries.
+func (c *Cache) Backward() iter.Seq2[string, Value] {
+	return func(yield func(string, Value) bool) {
+		entries := slices.Clone(c.pq)
+		slices.SortStableFunc(entries, func(a, b *entry) int {
+			return cmp.Compare(a.priority, b.priority)
+		})
+		for _, e := range entries {
+			if !yield(e.key, e.value) {
+				return
+			}
+		}
+	}
+}
+
+// evict evicts the entries of the lowest priority until the cache fits its capacity.
+func (c *Cache) evict() {
+	for c.capacity > 0 && c.size > c.capacity {
+		e := heap.Pop(&c.pq).(*entry)
+		c.inflate = e.priority
+		c.removeEntry(e)
+	}
+}
+
+// prioritize computes the priority of an entry.
+func (c *Cache) prioritize(e *entry) {
+	cost := e.cost
+	if cost <= 0 {
+		cost = minCost
+	}
+	e.priority = c.inflate + e.freq*cost/float64(max(size(e), 1))
+}
+
+// minCost is the cost of the entries with no positive cost.
+const minCost = 1e-9
+
+func (c *Cache) removeEntry(e *entry) {
+	delete(c.cache, e.key)
+	c.size -= size(e)
+	if c.onEvict != nil {
+		c.onEvict(e.key, e.value)
+	}
+}
+
+// size returns the size of an entry in bytes.
+func size(e *entry) int64 {
+	return int64(len(e.key)) + int64(e.value.Len())
+}
+
+// A queue is a min-heap of entries by priority.
+type queue []*entry
+
+func (q queue) Len() int           { return len(q) }
+func (q queue) Less(i, j int) bool { return q[i].priority < q[j].priority }
+
+func (q queue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *queue) Push(x any) {
+	e := x.(*entry)
+	e.index = len(*q)
+	*q = append(*q, e)
+}
+
+func (q *queue) Pop() any {
+	old := *q
+	e := old[len(old)-1]
+	old[len(old)-1] = nil
+	*q = old[:len(old)-1]
+	return e
+}
@@ -0,0 +1,109 @@
+package gdsf
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+type value string
+
+func (v value) Len() int {
+	return len(v)
+}
+
+func TestGet(t *testing.T) {
+	c := New(int64(0), nil)
+	c.Set("k1", value("v1"))
+	if v, ok := c.Get("k1"); !ok || string(v.(value)) != "v1" {
+		t.Fatalf("cache hit k1=v1 failed")
+	}
+	if _, ok := c.Get("k2"); ok {
+		t.Fatalf("cache miss k2 failed")
+	}
+	c.Set("k1", value("value1"))
+	if c.Size() != int64(len("k1"+"value1")) || c.Len() != 1 {
+		t.Fatalf("cache set failed (expected: %v, got: %v)", len("k1"+"value1"), c.Size())
+	}
+}
+
+func TestCost(t *testing.T) {
+	keys := []string{}
+	c := New(int64(len("k1"+"v1")*3), func(key string, value Value) {
+		keys = append(keys, key)
+	})
+	c.SetWithCost("k1", value("v1"), 2000)
+	c.SetWithCost("k2", value("v2"), 1)
+	c.SetWithCost("k3", value("v3"), 1)
+	c.Get("k2")
+	c.SetWithCost("k4", value("v4"), 1) // evicts k3, the cheapest and least frequently used
+	c.SetWithCost("k5", value("v5"), 1) // evicts k4, whose priority is below the inflated one of k5
+	if expected := []string{"k3", "k4"}; !reflect.DeepEqual(expected, keys) {
+		t.Fatalf("cache cost-aware eviction failed (expected: %v, got: %v)", expected, keys)
+	}
+	if _, ok := c.Get("k1"); !ok {
+		t.Fatalf("cache evicted the costly k1")
+	}
+}
+
+func TestSize(t *testing.T) {
+	keys := []string{}
+	c := New(int64(25), func(key string, value Value) {
+		keys = append(keys, key)
+	})
+	c.SetWithCost("large", value("0123456789"), 10)
+	c.SetWithCost("k1", value("v1"), 10)
+	c.SetWithCost("k2", value("v2"), 10)
+	c.SetWithCost("k3", value("v3"), 10)
+	// At the same cost and frequency, the large entry is evicted first.
+	if expected := []string{"large"}; !reflect.DeepEqual(expected, keys) {
+		t.Fatalf("cache size-aware eviction failed (expected: %v, got: %v)", expected, keys)
+	}
+}
+
+func TestAging(t *testing.T) {
+	c := New(int64(len("k01"+"v")*5), nil)
+	c.SetWithCost("old", value("v"), 1)
+	for range 5 {
+		c.Get("old")
+	}
+	// The entries set later age the frequently accessed old entry, which is eventually evicted.
+	for i := range 100 {
+		c.SetWithCost(fmt.Sprintf("k%02d", i), value("v"), 1)
+		c.Get(fmt.Sprintf("k%02d", i))
+	}
+	if _, ok := c.Get("old"); ok {
+		t.Fatalf("cache failed to age the old entry")
+	}
+}
+
+func TestBackward(t *testing.T) {
+	c := New(int64(0), nil)
+	c.SetWithCost("k1", value("v1"), 3)
+	c.SetWithCost("k2", value("v2"), 1)
+	c.SetWithCost("k3", value("v3"), 2)
+	keys := []string{}
+	for k := range c.Backward() {
+		keys = append(keys, k)
+	}
+	if expected := []string{"k2", "k3", "k1"}; !reflect.DeepEqual(expected, keys) {
+		t.Fatalf("cache backward iteration failed (expected: %v, got: %v)", expected, keys)
+	}
+}
+
+func TestRemove(t *testing.T) {
+	keys := []string{}
+	c := New(int64(0), func(key string, value Value) {
+		keys = append(keys, key)
+	})
+	c.Set("k1", value("v1"))
+	c.Set("k2", value("v2"))
+	c.Remove("k1")
+	c.Remove("k3")
+	if _, ok := c.Get("k1"); ok || c.Len() != 1 || c.Size() != int64(len("k2"+"v2")) {
+		t.Fatalf("cache remove k1 failed")
+	}
+	if expected := []string{"k1"}; !reflect.DeepEqual(expected, keys) {
+		t.Fatalf("cache remove callback failed (expected: %v, got: %v)", expected, keys)
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"log"
 	"sync"
+	"time"
 
+	"github.com/thezbm/gocache/diskcache"
 	pb "github.com/thezbm/gocache/gocachepb"
 	"github.com/thezbm/gocache/singleflight"
 )
@@ -21,13 +23,88 @@ func (f GetterFunc) Get(key string) ([]byte, error) {
 	return f(key)
 }
 
+// A ViewGetter is a Getter that can load the values as ByteViews, e.g. held as strings with StringView.
+// If the getter of a group implements ViewGetter, the group loads the values with GetView and stores them
+// without copying them.
+type ViewGetter interface {
+	Getter
+	GetView(key string) (ByteView, error)
+}
+
+// A ViewGetterFunc implements ViewGetter with a function.
+type ViewGetterFunc func(key string) (ByteView, error)
+
+func (f ViewGetterFunc) Get(key string) ([]byte, error) {
+	v, err := f(key)
+	return v.ByteSlice(), err
+}
+
+func (f ViewGetterFunc) GetView(key string) (ByteView, error) {
+	return f(key)
+}
+
+// A StringGetterFunc implements ViewGetter with a function loading the values as strings.
+type StringGetterFunc func(key string) (string, error)
+
+func (f StringGetterFunc) Get(key string) ([]byte, error) {
+	s, err := f(key)
+	return []byte(s), err
+}
+
+func (f StringGetterFunc) GetView(key string) (ByteView, error) {
+	s, err := f(key)
+	return StringView(s), err
+}
+
 // A Group is a cache namespace and associated data loaded spread over one or more nodes.
 type Group struct {
-	name      string
-	getter    Getter
-	mainCache cache
-	peers     PeerPicker
-	sg        singleflight.Group
+	name       string
+	getter     Getter
+	mainCache  cache
+	peers      PeerPicker
+	peerPush   bool      // whether side-loaded values are pushed to and accepted from peers
+	pushes     chan push // the side-loaded values waiting to be pushed to their owners
+	sg         singleflight.Group
+	disk       *diskcache.Cache // (optional) the second tier receiving entries evicted from mainCache
+	spills     chan item        // the entries evicted from mainCache waiting to be written to disk
+	compressor Compressor       // (optional) the compressor of the values in mainCache
+	adaptive   *Adaptive        // (optional) the controller adjusting the capacity of mainCache
+	evictHooks []EvictHook      // the hooks called for the entries leaving mainCache
+	noCopy     bool             // whether the slices returned by getter are stored without copying them
+	stats      groupStats
+	pinned     pinned   // the entries excluded from the eviction of mainCache
+	mrc        *sampler // (optional) the estimator of the miss-ratio curve of mainCache
+
+	maxEntrySize int64     // the maximum size of an entry of mainCache; <= 0 means the capacity of a shard
+	admission    Admission // what to do with the values larger than maxEntrySize
+	largeObjects *cache    // (optional) the area of the values larger than maxEntrySize
+
+	snapshotPath     string        // (optional) the file to restore from and snapshot to
+	snapshotInterval time.Duration // the interval of periodic snapshots; <= 0 means no periodic snapshots
+
+	closeOnce sync.Once
+	done      chan struct{}  // closed when the group is closed
+	wg        sync.WaitGroup // tracks the background goroutines
+}
+
+// A GroupOption configures a Group.
+type GroupOption func(*Group)
+
+// WithDiskCache makes the disk cache the second tier of the group.
+// Entries evicted from the main cache are written to it, and it is consulted before peers and the getter.
+// A disk cache must not be shared by multiple groups. It is owned and closed by the caller.
+func WithDiskCache(disk *diskcache.Cache) GroupOption {
+	return func(g *Group) {
+		g.disk = disk
+	}
+}
+
+// WithoutCopy makes the group store the slices returned by its getter as they are, instead of copies of them.
+// The getter must hand over their ownership, and neither modify nor reuse them afterwards.
+func WithoutCopy() GroupOption {
+	return func(g *Group) {
+		g.noCopy = true
+	}
 }
 
 var (
@@ -37,19 +114,51 @@ var (
 
 // NewGroup creates a new instance of Group.
 // A 0 capacity means no limit of the cache size.
-func NewGroup(name string, capacity int64, getter Getter) *Group {
+func NewGroup(name string, capacity int64, getter Getter, opts ...GroupOption) *Group {
 	if getter == nil {
 		panic("getter is nil")
 	}
-	mu.Lock()
-	defer mu.Unlock()
 	g := &Group{
 		name:      name,
 		getter:    getter,
 		mainCache: cache{capacity: capacity},
 		sg:        singleflight.Group{},
+		done:      make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(g)
 	}
+	if g.mrc != nil && len(g.mrc.capacities) == 0 {
+		g.mrc = g.defaultCurve(g.mrc.rate)
+	}
+	if g.disk != nil {
+		g.spills = make(chan item, spillQueueSize)
+		g.evictHooks = append(g.evictHooks, g.spillToDisk)
+		g.wg.Add(1)
+		go g.spillLoop()
+	}
+	if g.peerPush {
+		g.pushes = make(chan push, pushQueueSize)
+		g.wg.Add(1)
+		go g.pushLoop()
+	}
+	if len(g.evictHooks) > 0 {
+		g.mainCache.onEvict = g.evicted
+		if g.largeObjects != nil {
+			g.largeObjects.onEvict = g.evicted
+		}
+	}
+	if g.snapshotPath != "" {
+		// The snapshot is restored before the group is registered, so that its file I/O does not block GetGroup.
+		g.restoreSnapshot()
+		if g.snapshotInterval > 0 {
+			g.wg.Add(1)
+			go g.snapshotLoop()
+		}
+	}
+	mu.Lock()
 	groups[name] = g
+	mu.Unlock()
 	return g
 }
 
@@ -65,62 +174,279 @@ func GetGroup(name string) *Group {
 // Get gets the value for the given key from the cache.
 // If the key does not exist, it loads the value.
 func (g *Group) Get(key string) (ByteView, error) {
+	value, err := g.get(key)
+	if err != nil {
+		return ByteView{}, err
+	}
+	return value.decompress()
+}
+
+// get is like Get but returns the value as it is stored, which may be compressed.
+func (g *Group) get(key string) (ByteView, error) {
 	if key == "" {
 		return ByteView{}, fmt.Errorf("key is required")
 	}
-	if v, ok := g.mainCache.get(key); ok {
+	g.stats.gets.Add(1)
+	if v, ok := g.lookupCache(key); ok {
+		g.stats.hits.Add(1)
+		g.sample(key, v)
 		log.Printf("[gocache] hit with key=%s", key)
 		return v, nil
 	}
-	value, err := g.sg.Do(key, func() (any, error) {
+	l, err := g.sg.Do(key, func() (any, error) {
+		g.stats.loads.Add(1)
 		return g.load(key)
 	})
-	return value.(ByteView), err
+	// The values of the keys owned by peers are not cached by the group, so they are not sampled.
+	if err == nil && !l.(loaded).remote {
+		g.sample(key, l.(loaded).value)
+	}
+	return l.(loaded).value, err
+}
+
+// SetCapacity changes the capacity of the main cache of the group to capacity bytes at runtime,
+// evicting entries if it shrinks; capacity <= 0 means no limit.
+// With WithAdaptive, it also becomes the capacity the group grows back to.
+func (g *Group) SetCapacity(capacity int64) {
+	if g.adaptive != nil {
+		g.adaptive.configure(g, capacity)
+	}
+	g.mainCache.setCapacity(capacity)
+	log.Printf("[gocache] set capacity of group=%s to %d bytes", g.name, capacity)
+}
+
+// Capacity returns the capacity of the main cache of the group in bytes.
+func (g *Group) Capacity() int64 {
+	return g.mainCache.getCapacity()
 }
 
-// Load loads the value either from its peers or from the local node by calling the getter.
-func (g *Group) load(key string) (ByteView, error) {
+// A loaded value, with whether it was loaded from a peer.
+type loaded struct {
+	value  ByteView
+	remote bool
+}
+
+// Load loads the value either from the disk tier, its peers or from the local node by calling the getter.
+func (g *Group) load(key string) (loaded, error) {
+	if value, ok := g.getFromDisk(key); ok {
+		return loaded{value: value}, nil
+	}
 	if g.peers != nil {
 		if peer, ok := g.peers.PickPeer(key); ok {
 			value, err := g.getFromPeer(peer, key)
 			if err == nil {
-				return value, nil
+				g.stats.peerLoads.Add(1)
+				return loaded{value: value, remote: true}, nil
 			}
 			log.Println("[gocache] failed to get from peer", err)
 		}
 	}
-	return g.getLocally(key)
+	value, err := g.getLocally(key)
+	return loaded{value: value}, err
 }
 
 // getLocally loads the value using the getter and stores it in the cache.
 func (g *Group) getLocally(key string) (ByteView, error) {
-	bytes, err := g.getter.Get(key)
+	var value ByteView
+	var pin bool
+	var err error
+	var elapsed time.Duration // the latency of the getter
+	var batched bool
+	start := time.Now()
+	switch getter := g.getter.(type) {
+	case ResultGetter:
+		var r LoadResult
+		if b, ok := getter.(*batcher); ok {
+			// The wait for the batch to fill up is no cost of loading the value.
+			r, elapsed, err = b.getResult(key)
+			batched = true
+		} else {
+			r, err = getter.GetResult(key)
+		}
+		value, pin = r.Value, r.Pin
+		value.meta = newValueMeta(r)
+		if err == nil && len(r.Extra) > 0 {
+			defer g.sideLoad(key, r.Extra)
+		}
+	case PinGetter:
+		var bytes []byte
+		bytes, pin, err = getter.GetPinned(key)
+		value = g.view(bytes)
+	case ViewGetter:
+		value, err = getter.GetView(key)
+	default:
+		var bytes []byte
+		bytes, err = getter.Get(key)
+		value = g.view(bytes)
+	}
+	if !batched {
+		elapsed = time.Since(start)
+	}
 	if err != nil {
 		return ByteView{}, err
 	}
-	value := ByteView{bytes: copyBytes(bytes)}
-	g.populateCache(key, value)
+	g.stats.localLoads.Add(1)
+	value = g.compress(value)
+	// The cost is the latency of the getter alone, not of the compression.
+	value.cost = elapsed
+	switch {
+	case value.meta != nil && value.meta.noCache:
+	case pin:
+		g.populatePinned(key, value)
+	default:
+		g.populateCache(key, value)
+	}
 	log.Printf("[gocache] load with key=%s", key)
 	return value, nil
 }
 
-// populateCache stores the value in the cache of the group.
-func (g *Group) populateCache(key string, value ByteView) {
-	g.mainCache.set(key, value)
+// view returns the view of the bytes returned by the getter, copied unless the group is created WithoutCopy.
+func (g *Group) view(bytes []byte) ByteView {
+	if g.noCopy {
+		return ByteView{bytes: bytes}
+	}
+	return ByteView{bytes: copyBytes(bytes)}
+}
+
+// compress returns the view of value to be stored in the cache, compressed if the group has a compressor.
+func (g *Group) compress(value ByteView) ByteView {
+	if g.compressor == nil {
+		return value
+	}
+	return compressWith(g.compressor, value)
+}
+
+// compressWith returns the view of value compressed with c, or value if it does not compress.
+func compressWith(c Compressor, value ByteView) ByteView {
+	compressed, err := c.Compress(value.data())
+	if err != nil {
+		log.Printf("[gocache] failed to compress with %s: %v", c.Name(), err)
+		return value
+	}
+	if len(compressed) >= value.Len() {
+		return value
+	}
+	return ByteView{bytes: compressed, c: c, cost: value.cost, meta: value.meta}
+}
+
+// getFromDisk retrieves the value from the disk tier and promotes it to the main cache.
+func (g *Group) getFromDisk(key string) (ByteView, bool) {
+	if g.disk == nil {
+		return ByteView{}, false
+	}
+	bytes, ok := g.disk.Get(key)
+	if !ok {
+		return ByteView{}, false
+	}
+	g.stats.diskHits.Add(1)
+	value := g.compress(ByteView{bytes: bytes})
+	g.populateCache(key, value)
+	// The value is back in memory, and spilled again when it is evicted.
+	if err := g.disk.Remove(key); err != nil {
+		log.Printf("[gocache] failed to remove key=%s from disk: %v", key, err)
+	}
+	log.Printf("[gocache] disk hit with key=%s", key)
+	return value, true
+}
+
+// spillQueueSize is the number of evicted entries that can wait to be written to the disk tier.
+// The entries evicted while the queue is full are not spilled.
+const spillQueueSize = 1024
+
+// spillToDisk queues an entry evicted from the main cache for its capacity to be written to the disk tier.
+// It runs under the lock of a shard, so the disk I/O is left to spillLoop.
+func (g *Group) spillToDisk(key string, value ByteView, reason EvictReason) {
+	// The disk tier only keeps the bytes of the values, so the values with metadata are not spilled.
+	if reason != EvictCapacity || value.meta != nil {
+		return
+	}
+	select {
+	case g.spills <- item{key: key, value: value}:
+	default:
+		log.Printf("[gocache] drop spill of key=%s: the disk queue is full", key)
+	}
+}
+
+// spillLoop writes the queued entries to the disk tier until the group is closed,
+// then writes the ones still queued.
+func (g *Group) spillLoop() {
+	defer g.wg.Done()
+	for {
+		select {
+		case it := <-g.spills:
+			g.writeSpill(it)
+		case <-g.done:
+			for {
+				select {
+				case it := <-g.spills:
+					g.writeSpill(it)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// writeSpill writes an evicted entry to the disk tier.
+func (g *Group) writeSpill(it item) {
+	if err := g.disk.Set(it.key, it.value.data()); err != nil {
+		log.Printf("[gocache] failed to spill key=%s to disk: %v", it.key, err)
+	}
 }
 
 // getFromPeer retrieves the value from the peer.
+// The value is transferred compressed if the peer stores it with the same kind of compressor.
 func (g *Group) getFromPeer(peer Peer, key string) (ByteView, error) {
 	req := &pb.Request{
 		Group: g.name,
 		Key:   key,
 	}
+	if g.compressor != nil {
+		req.AcceptEncoding = g.compressor.Name()
+	}
 	resp := &pb.Response{}
 	err := peer.Get(req, resp)
 	if err != nil {
 		return ByteView{}, err
 	}
-	return ByteView{bytes: resp.Value}, nil
+	value := ByteView{bytes: resp.Value, meta: responseMeta(resp)}
+	if resp.Encoding == "" {
+		return value, nil
+	}
+	if resp.Encoding != req.AcceptEncoding {
+		return ByteView{}, fmt.Errorf("peer returned unaccepted encoding %q", resp.Encoding)
+	}
+	value.c = g.compressor
+	return value, nil
+}
+
+// response returns the response to a peer's request for key.
+// The value is kept compressed if the peer accepts its encoding.
+func (g *Group) response(key string, acceptEncoding string) (*pb.Response, error) {
+	value, err := g.get(key)
+	if err != nil {
+		return nil, err
+	}
+	var resp *pb.Response
+	if value.c != nil && value.c.Name() == acceptEncoding {
+		resp = &pb.Response{Value: value.bytes, Encoding: acceptEncoding}
+	} else {
+		if value, err = value.decompress(); err != nil {
+			return nil, err
+		}
+		resp = &pb.Response{Value: value.data()}
+	}
+	if value.meta != nil {
+		if ttl := value.ttl(); ttl > 0 {
+			resp.TtlMs = max(ttl.Milliseconds(), 1)
+		}
+		resp.ContentType = value.meta.contentType
+		resp.Version = value.meta.version
+		resp.NoCache = value.meta.noCache
+		resp.Metadata = value.meta.metadata
+	}
+	return resp, nil
 }
 
 // RegisterPeers registers a PeerPicker for choosing remote peers.
@@ -130,3 +456,23 @@ func (g *Group) RegisterPeers(peers PeerPicker) {
 	}
 	g.peers = peers
 }
+
+// Close stops the background work of the group and detaches it from a budget or an adaptive controller.
+// If the group has a snapshot file, a final snapshot is written to it.
+func (g *Group) Close() error {
+	var err error
+	g.closeOnce.Do(func() {
+		close(g.done)
+		g.wg.Wait()
+		if g.snapshotPath != "" {
+			err = g.SnapshotFile(g.snapshotPath)
+		}
+		if g.mainCache.budget != nil {
+			g.mainCache.budget.leave()
+		}
+		if g.adaptive != nil {
+			g.adaptive.leave(g)
+		}
+	})
+	return err
+}
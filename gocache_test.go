@@ -3,7 +3,11 @@ package gocache
 import (
 	"fmt"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/thezbm/gocache/diskcache"
 )
 
 func TestGetter(t *testing.T) {
@@ -62,3 +66,105 @@ func TestGetGroup(t *testing.T) {
 		t.Fatalf("GetGroup failed with key=testGroup2 (expected an error, got: %s)", group.name)
 	}
 }
+
+func TestDiskCache(t *testing.T) {
+	disk, err := diskcache.Open(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("open disk cache failed: %v", err)
+	}
+	defer disk.Close()
+	loads := 0
+	g := NewGroup("disk", int64(len("k1"+"v1")), GetterFunc(
+		func(key string) ([]byte, error) {
+			loads++
+			return []byte(strings.Replace(key, "k", "v", 1)), nil
+		}), WithDiskCache(disk))
+
+	defer g.Close()
+
+	g.Get("k1")
+	g.Get("k2") // evicts k1 to disk
+	waitDisk(t, disk, "k1", true)
+	if v, err := g.Get("k1"); err != nil || v.String() != "v1" || loads != 2 {
+		t.Fatalf("cache Get failed to hit disk with key=k1 (loads: %d)", loads)
+	}
+	// The promotion of k1 removes it from disk, and evicts k2 to disk.
+	waitDisk(t, disk, "k2", true)
+	if _, ok := disk.Get("k1"); ok {
+		t.Fatalf("cache failed to remove the promoted key=k1 from disk")
+	}
+}
+
+// waitDisk waits for the background spills to store (or not) key in disk.
+func waitDisk(t *testing.T, disk *diskcache.Cache, key string, stored bool) {
+	for range 100 {
+		if _, ok := disk.Get(key); ok == stored {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("disk cache failed to spill key=%s", key)
+}
+
+func TestSetCapacity(t *testing.T) {
+	// Every entry is 4 + 4 bytes, e.g. key=k001 and value=k001.
+	g := NewGroup("set_capacity", 8*10, GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte(key), nil
+		}), WithShards(1))
+	defer g.Close()
+
+	for i := range 10 {
+		g.Get(fmt.Sprintf("k%03d", i))
+	}
+	g.SetCapacity(8 * 4)
+	if g.Capacity() != 8*4 || g.mainCache.len() != 4 {
+		t.Fatalf("group failed to shrink (expected: %v, got: %v)", 4, g.mainCache.len())
+	}
+	if _, ok := g.mainCache.get("k009"); !ok {
+		t.Fatalf("group evicted the most recent key when shrinking")
+	}
+	g.SetCapacity(8 * 20)
+	for i := range 20 {
+		g.Get(fmt.Sprintf("k%03d", i))
+	}
+	if g.mainCache.len() != 20 {
+		t.Fatalf("group failed to grow (expected: %v, got: %v)", 20, g.mainCache.len())
+	}
+}
+
+func TestViewGetter(t *testing.T) {
+	g := NewGroup("view_getter", 1<<10, StringGetterFunc(
+		func(key string) (string, error) {
+			return "value of " + key, nil
+		}))
+	defer g.Close()
+
+	if v, err := g.Get("key"); err != nil || v.String() != "value of key" {
+		t.Fatalf("cache Get failed with a StringGetterFunc (got: %v)", v)
+	}
+	if v, ok := g.mainCache.get("key"); !ok || v.bytes != nil || v.str != "value of key" {
+		t.Fatalf("group converted the string value to bytes")
+	}
+}
+
+func TestWithoutCopy(t *testing.T) {
+	values := map[string][]byte{}
+	getter := GetterFunc(func(key string) ([]byte, error) {
+		values[key] = []byte(key)
+		return values[key], nil
+	})
+	for _, noCopy := range []bool{false, true} {
+		var opts []GroupOption
+		if noCopy {
+			opts = append(opts, WithoutCopy())
+		}
+		g := NewGroup("without_copy", 1<<10, getter, opts...)
+		g.Get("key")
+		v, _ := g.mainCache.get("key")
+		if shared := &v.bytes[0] == &values["key"][0]; shared != noCopy {
+			t.Fatalf("group copy of the getter's value failed (expected shared: %v, got: %v)", noCopy, shared)
+		}
+		g.Close()
+	}
+}
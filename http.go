@@ -1,12 +1,17 @@
 package gocache
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/thezbm/gocache/consistenthash"
 	pb "github.com/thezbm/gocache/gocachepb"
@@ -56,13 +61,13 @@ func (p *HTTPPool) GetHTTPHandler() http.Handler {
 			return
 		}
 
-		view, err := group.Get(key)
+		resp, err := group.response(key, r.URL.Query().Get("accept_encoding"))
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		body, err := proto.Marshal(&pb.Response{Value: view.ByteSlice()})
+		body, err := proto.Marshal(resp)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -73,6 +78,42 @@ func (p *HTTPPool) GetHTTPHandler() http.Handler {
 		w.Write(body)
 	})
 
+	// Handle PUT /<basePath>/<groupname>/<key> with the value pushed by a peer in the Protocol Buffer request,
+	// if the group accepts it, see WithPeerPush.
+	pattern = fmt.Sprintf("PUT %s/{group}/{key}", p.basePath)
+	mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		p.Log("%s %s", r.Method, r.URL.Path)
+		groupName, key := r.PathValue("group"), r.PathValue("key")
+
+		group := GetGroup(groupName)
+		if group == nil {
+			http.Error(w, "group not found: "+groupName, http.StatusNotFound)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, group.pushLimit()))
+		if err != nil {
+			status := http.StatusBadRequest
+			if _, ok := err.(*http.MaxBytesError); ok {
+				status = http.StatusRequestEntityTooLarge
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		req := &pb.SetRequest{}
+		if err := proto.Unmarshal(body, req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.Key = key
+
+		if err := group.setFromPeer(req); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
 	// Handle bad requests.
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		p.Log("bad request: %s", r.URL.Path)
@@ -82,6 +123,122 @@ func (p *HTTPPool) GetHTTPHandler() http.Handler {
 	return mux
 }
 
+// AdminHandler returns the HTTP handler for the administration endpoints of the pool,
+// which read the statistics of the groups and change their capacity.
+// It is not part of GetHTTPHandler and must be mounted explicitly, never where the peers or clients can reach it.
+func (p *HTTPPool) AdminHandler() http.Handler {
+	mux := http.NewServeMux()
+
+	// Handle GET /<basePath>/_admin/stats/<groupname> with the statistics of the group in JSON.
+	pattern := fmt.Sprintf("GET %s/_admin/stats/{group}", p.basePath)
+	mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		p.Log("%s %s", r.Method, r.URL.Path)
+		groupName := r.PathValue("group")
+
+		group := GetGroup(groupName)
+		if group == nil {
+			http.Error(w, "group not found: "+groupName, http.StatusNotFound)
+			return
+		}
+
+		body, err := json.Marshal(group.Stats())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	})
+
+	// Handle PUT /<basePath>/_admin/capacity/<groupname> with the new capacity of the group in bytes as the body.
+	pattern = fmt.Sprintf("PUT %s/_admin/capacity/{group}", p.basePath)
+	mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		p.Log("%s %s", r.Method, r.URL.Path)
+		groupName := r.PathValue("group")
+
+		group := GetGroup(groupName)
+		if group == nil {
+			http.Error(w, "group not found: "+groupName, http.StatusNotFound)
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, 64))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		capacity, err := strconv.ParseInt(strings.TrimSpace(string(body)), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid capacity: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if capacity < 0 {
+			http.Error(w, "invalid capacity: must not be negative", http.StatusBadRequest)
+			return
+		}
+
+		group.SetCapacity(capacity)
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	return mux
+}
+
+// ServeByteView writes the view as the body of the response to r, without copying it.
+// It handles Range and conditional requests with http.ServeContent, and sets the content type
+// to generic binary data unless it is already set.
+func ServeByteView(w http.ResponseWriter, r *http.Request, v ByteView) {
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/octet-stream")
+	}
+	http.ServeContent(w, r, "", time.Time{}, v.Reader())
+}
+
+// GroupHandler returns an HTTP handler serving the values of the group by the key in the "key" query parameter,
+// e.g. GET /api?key=Tom, for clients of the cache rather than its peers.
+// The metadata of the values is served as headers, see SetResultHeaders.
+func GroupHandler(g *Group) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		result, err := g.GetResult(r.URL.Query().Get("key"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		SetResultHeaders(w.Header(), result)
+		ServeByteView(w, r, result.Value)
+	})
+}
+
+// metadataHeaderPrefix is the prefix of the headers of the metadata of a LoadResult.
+const metadataHeaderPrefix = "X-Gocache-Meta-"
+
+// SetResultHeaders sets the headers describing the metadata of a LoadResult:
+// Content-Type, ETag from the version, Cache-Control from the TTL and NoCache,
+// and X-Gocache-Meta-<name> for every metadata entry.
+func SetResultHeaders(h http.Header, r LoadResult) {
+	if r.ContentType != "" {
+		h.Set("Content-Type", r.ContentType)
+	}
+	if r.Version != "" {
+		etag := r.Version
+		if !strings.HasPrefix(etag, `"`) && !strings.HasPrefix(etag, `W/"`) {
+			etag = `"` + etag + `"`
+		}
+		h.Set("ETag", etag)
+	}
+	switch {
+	case r.NoCache:
+		h.Set("Cache-Control", "no-store")
+	case r.TTL > 0:
+		// Round up, so that a TTL under a second does not make the value stale right away.
+		h.Set("Cache-Control", fmt.Sprintf("max-age=%d", int64((r.TTL+time.Second-1)/time.Second)))
+	}
+	for k, v := range r.Metadata {
+		h.Set(metadataHeaderPrefix+k, v)
+	}
+}
+
 // SetPeers sets the peers for the pool with their base URLs.
 func (p *HTTPPool) SetPeers(peers ...string) {
 	p.mu.Lock()
@@ -117,8 +274,12 @@ type httpPeer struct {
 
 // Get sends a GET request to the remote peer for the given group and key in the Protocol Buffer request.
 func (h *httpPeer) Get(in *pb.Request, out *pb.Response) error {
-	url := fmt.Sprintf("%s/%s/%s",
-		h.baseURL, url.QueryEscape(in.GetGroup()), url.QueryEscape(in.GetKey()))
+	var query string
+	if enc := in.GetAcceptEncoding(); enc != "" {
+		query = "?accept_encoding=" + url.QueryEscape(enc)
+	}
+	url := fmt.Sprintf("%s/%s/%s%s",
+		h.baseURL, url.QueryEscape(in.GetGroup()), url.QueryEscape(in.GetKey()), query)
 	resp, err := http.Get(url)
 	if err != nil {
 		return err
@@ -139,3 +300,27 @@ func (h *httpPeer) Get(in *pb.Request, out *pb.Response) error {
 	}
 	return nil
 }
+
+// Set sends a PUT request to the remote peer for the given group and key with the Protocol Buffer request.
+func (h *httpPeer) Set(in *pb.SetRequest) error {
+	body, err := proto.Marshal(in)
+	if err != nil {
+		return err
+	}
+	url := fmt.Sprintf("%s/%s/%s", h.baseURL, url.QueryEscape(in.GetGroup()), url.QueryEscape(in.GetKey()))
+	req, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("endpoint %s returned: %s", h.baseURL, resp.Status)
+	}
+	return nil
+}
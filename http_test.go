@@ -1,11 +1,18 @@
 package gocache
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+
+	pb "github.com/thezbm/gocache/gocachepb"
+
+	"google.golang.org/protobuf/proto"
 )
 
 func TestHTTPPool(t *testing.T) {
@@ -16,9 +23,11 @@ func TestHTTPPool(t *testing.T) {
 		}))
 
 	resp := poolFetch(p, "identity", "key")
+	b, _ := io.ReadAll(resp.Body)
 	resp.Body.Close()
-	if b, _ := io.ReadAll(resp.Body); resp.StatusCode != http.StatusOK || string(b) != "key" {
-		t.Fatalf("HTTP Get failed (expected: key, got: %s)", b)
+	out := &pb.Response{}
+	if err := proto.Unmarshal(b, out); err != nil || resp.StatusCode != http.StatusOK || string(out.Value) != "key" {
+		t.Fatalf("HTTP Get failed (expected: key, got: %s)", out.Value)
 	}
 
 	resp = poolFetch(p, "nonexistent", "key")
@@ -27,6 +36,157 @@ func TestHTTPPool(t *testing.T) {
 	}
 }
 
+func TestHTTPStats(t *testing.T) {
+	p := NewHTTPPool("localhost:8080")
+	g := NewGroup("http_stats", 0, GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte(key), nil
+		}), WithMissRatioCurve(1, 1<<10))
+	g.Get("key")
+	g.Get("key")
+
+	resp := adminFetch(p, "GET", "stats/http_stats", "")
+	defer resp.Body.Close()
+	stats := Stats{}
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("HTTP stats failed (status: %v, err: %v)", resp.StatusCode, err)
+	}
+	if stats.Gets != 2 || stats.Hits != 1 || len(stats.MissRatioCurve) != 1 || stats.MissRatioCurve[0].MissRatio != 0.5 {
+		t.Fatalf("HTTP stats failed (got: %+v)", stats)
+	}
+
+	resp = adminFetch(p, "GET", "stats/nonexistent", "")
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("HTTP stats failed (expected: %v, got: %v)", http.StatusNotFound, resp.StatusCode)
+	}
+
+	// The peer handler does not serve the admin endpoints.
+	resp = poolFetch(p, "_admin/stats", "http_stats")
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("HTTP stats served by the peer handler (status: %v)", resp.StatusCode)
+	}
+}
+
+func TestHTTPCapacity(t *testing.T) {
+	p := NewHTTPPool("localhost:8080")
+	g := NewGroup("http_capacity", 1<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte(key), nil
+		}))
+
+	resp := adminFetch(p, "PUT", "capacity/http_capacity", "2048")
+	if resp.StatusCode != http.StatusNoContent || g.Capacity() != 2048 {
+		t.Fatalf("HTTP capacity failed (status: %v, capacity: %v)", resp.StatusCode, g.Capacity())
+	}
+
+	for _, body := range []string{"2 KiB", "-1"} {
+		resp = adminFetch(p, "PUT", "capacity/http_capacity", body)
+		if resp.StatusCode != http.StatusBadRequest || g.Capacity() != 2048 {
+			t.Fatalf("HTTP capacity %q failed (expected: %v, got: %v)", body, http.StatusBadRequest, resp.StatusCode)
+		}
+	}
+
+	// The peer handler does not serve the admin endpoints.
+	req := httptest.NewRequest("PUT", p.basePath+"/_admin/capacity/http_capacity", strings.NewReader("1"))
+	w := httptest.NewRecorder()
+	p.GetHTTPHandler().ServeHTTP(w, req)
+	if w.Code == http.StatusNoContent || g.Capacity() != 2048 {
+		t.Fatalf("HTTP capacity served by the peer handler (status: %v)", w.Code)
+	}
+}
+
+func TestGroupHandler(t *testing.T) {
+	g := NewGroup("group_handler", 1<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte("hello " + key), nil
+		}))
+
+	req := httptest.NewRequest("GET", "/api?key=world", nil)
+	req.Header.Set("Range", "bytes=6-")
+	w := httptest.NewRecorder()
+	GroupHandler(g).ServeHTTP(w, req)
+	if w.Code != http.StatusPartialContent || w.Body.String() != "world" {
+		t.Fatalf("HTTP range failed (expected: world, got: %s)", w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/octet-stream" {
+		t.Fatalf("HTTP content type failed (expected: application/octet-stream, got: %v)", ct)
+	}
+}
+
+func TestGroupHandlerHeaders(t *testing.T) {
+	g := NewGroup("group_handler_headers", 1<<10, resultGetter(map[string]int{}))
+
+	w := httptest.NewRecorder()
+	GroupHandler(g).ServeHTTP(w, httptest.NewRequest("GET", "/api?key=key", nil))
+	expected := map[string]string{
+		"Content-Type":         "text/plain",
+		"Etag":                 `"v1"`,
+		"X-Gocache-Meta-Owner": "test",
+	}
+	for k, v := range expected {
+		if got := w.Header().Get(k); got != v {
+			t.Fatalf("HTTP header %v failed (expected: %v, got: %v)", k, v, got)
+		}
+	}
+	if cc := w.Header().Get("Cache-Control"); cc != "" {
+		t.Fatalf("HTTP header Cache-Control failed (expected: none, got: %v)", cc)
+	}
+
+	w = httptest.NewRecorder()
+	GroupHandler(g).ServeHTTP(w, httptest.NewRequest("GET", "/api?key=nocache", nil))
+	if cc := w.Header().Get("Cache-Control"); cc != "no-store" {
+		t.Fatalf("HTTP header Cache-Control failed (expected: no-store, got: %v)", cc)
+	}
+
+	w = httptest.NewRecorder()
+	GroupHandler(g).ServeHTTP(w, httptest.NewRequest("GET", "/api?key=live", nil))
+	if cc := w.Header().Get("Cache-Control"); cc != "max-age=1" {
+		t.Fatalf("HTTP header Cache-Control failed (expected: max-age=1, got: %v)", cc)
+	}
+
+	req := httptest.NewRequest("GET", "/api?key=key", nil)
+	req.Header.Set("If-None-Match", `"v1"`)
+	w = httptest.NewRecorder()
+	GroupHandler(g).ServeHTTP(w, req)
+	if w.Code != http.StatusNotModified {
+		t.Fatalf("HTTP conditional request failed (expected: %v, got: %v)", http.StatusNotModified, w.Code)
+	}
+}
+
+func TestHTTPPeerSet(t *testing.T) {
+	p := NewHTTPPool("localhost:8080")
+	getter := GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	})
+	g := NewGroup("http_peer_set", 1<<10, getter, WithPeerPush())
+	NewGroup("http_peer_set_private", 1<<10, getter)
+	server := httptest.NewServer(p.GetHTTPHandler())
+	defer server.Close()
+
+	peer := &httpPeer{baseURL: server.URL + p.basePath}
+	err := peer.Set(&pb.SetRequest{Group: "http_peer_set", Key: "a/key", Value: []byte("value"), Version: "v1"})
+	if err != nil {
+		t.Fatalf("HTTP Set failed: %v", err)
+	}
+	if r, err := g.GetResult("a/key"); err != nil || r.Value.String() != "value" || r.Version != "v1" {
+		t.Fatalf("HTTP Set failed (expected: value, got: %v)", r.Value)
+	}
+	if err := peer.Set(&pb.SetRequest{Group: "nonexistent", Key: "key"}); err == nil {
+		t.Fatalf("HTTP Set to a nonexistent group succeeded")
+	}
+	if err := peer.Set(&pb.SetRequest{Group: "http_peer_set_private", Key: "key"}); err == nil {
+		t.Fatalf("HTTP Set to a group without WithPeerPush succeeded")
+	}
+
+	body, _ := proto.Marshal(&pb.SetRequest{Value: make([]byte, g.pushLimit())})
+	req := httptest.NewRequest("PUT", p.basePath+"/http_peer_set/large", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	p.GetHTTPHandler().ServeHTTP(w, req)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("HTTP Set of a large value failed (expected: %v, got: %v)", http.StatusRequestEntityTooLarge, w.Code)
+	}
+}
+
 // poolFetch sends a GET request to the HTTP pool and returns the response.
 func poolFetch(p *HTTPPool, group string, key string) *http.Response {
 	endpoint := fmt.Sprintf("%s/%s/%s", p.basePath, group, key)
@@ -36,3 +196,10 @@ func poolFetch(p *HTTPPool, group string, key string) *http.Response {
 	resp := w.Result()
 	return resp
 }
+
+func adminFetch(p *HTTPPool, method string, path string, body string) *http.Response {
+	req := httptest.NewRequest(method, p.basePath+"/_admin/"+path, strings.NewReader(body))
+	w := httptest.NewRecorder()
+	p.AdminHandler().ServeHTTP(w, req)
+	return w.Result()
+}
@@ -1,20 +1,55 @@
 package lru
 
-import "container/list"
+import (
+	"container/list"
+	"fmt"
+	"iter"
+	"unsafe"
+)
 
-// An LRU cache.
-type Cache struct {
-	capacity int64                         // the maximum size of the cache; capacity <= 0 means no limit
-	size     int64                         // the current size of the cache
-	ll       *list.List                    // the underlying doubly linked list
-	cache    map[string]*list.Element      // the key to element mapping
-	onEvict  func(key string, value Value) // (optional) callback when an entry is evicted
+// An LRU cache of string keys and Values, as the cache of this package was before it had type parameters.
+type Cache = GCache[string, Value]
+
+// An LRU cache of values V by keys K.
+type GCache[K comparable, V any] struct {
+	capacity int64                                    // the maximum size of the cache; capacity <= 0 means no limit
+	maxEntry int64                                    // the maximum size of an entry; maxEntry <= 0 means the capacity
+	size     int64                                    // the current size of the cache
+	sizeOf   func(key K, value V) int64               // (optional) the size of an entry; nil means 1
+	ll       *list.List                               // the underlying doubly linked list
+	cache    map[K]*list.Element                      // the key to element mapping
+	onEvict  func(key K, value V, reason EvictReason) // (optional) callback when an entry is evicted
 }
 
 // The element in the linked list. The KV pair of the cache.
-type entry struct {
-	key   string
-	value Value
+type entry[K comparable, V any] struct {
+	key   K
+	value V
+	size  int64
+}
+
+// An EvictReason tells why an entry left the cache.
+type EvictReason int
+
+const (
+	EvictCapacity EvictReason = iota // evicted to respect the capacity
+	EvictRemoved                     // removed by Remove or RemoveOldest
+	EvictReplaced                    // replaced by Set; the key stays in the cache with the new value
+	EvictCleared                     // removed by Clear
+)
+
+func (r EvictReason) String() string {
+	switch r {
+	case EvictCapacity:
+		return "capacity"
+	case EvictRemoved:
+		return "removed"
+	case EvictReplaced:
+		return "replaced"
+	case EvictCleared:
+		return "cleared"
+	}
+	return fmt.Sprintf("EvictReason(%d)", int(r))
 }
 
 // A Value in the cache implements the Len method to return its size in bytes.
@@ -22,58 +57,206 @@ type Value interface {
 	Len() int // the size in bytes
 }
 
-// The constructor of Cache.
+// EntryOverhead returns the estimated memory used by a GCache[K, V] for each entry in addition to the memory
+// referenced by its key and value: the list element, the entry, and its slot in the map.
+func EntryOverhead[K comparable, V any]() int64 {
+	var key K
+	element := int64(unsafe.Sizeof(list.Element{}))
+	kv := int64(unsafe.Sizeof(entry[K, V]{}))
+	slot := int64(unsafe.Sizeof(key)+unsafe.Sizeof((*list.Element)(nil))) + 1 // the key, the element and a control byte
+	// A map is at most 7/8 full, and half full right after growing.
+	return element + kv + slot*3/2
+}
+
+// The constructor of Cache with string keys and Values, whose entries have size len(key) + value.Len().
+// The onEvict callback is not called for replaced values.
 func New(capacity int64, onEvict func(string, Value)) *Cache {
-	return &Cache{
+	var evict func(string, Value, EvictReason)
+	if onEvict != nil {
+		evict = func(key string, value Value, reason EvictReason) {
+			if reason != EvictReplaced {
+				onEvict(key, value)
+			}
+		}
+	}
+	return NewCache(capacity, func(key string, value Value) int64 {
+		return int64(len(key)) + int64(value.Len())
+	}, evict)
+}
+
+// The constructor of GCache.
+// The capacity is in the unit of sizeOf. If sizeOf is nil, every entry has size 1 and the capacity
+// is the maximum number of entries.
+func NewCache[K comparable, V any](capacity int64, sizeOf func(K, V) int64, onEvict func(K, V, EvictReason)) *GCache[K, V] {
+	return &GCache[K, V]{
 		capacity: capacity,
+		sizeOf:   sizeOf,
 		ll:       list.New(),
-		cache:    make(map[string]*list.Element),
+		cache:    make(map[K]*list.Element),
 		onEvict:  onEvict,
 	}
 }
 
 // Get gets the value from the cache by key.
-func (c *Cache) Get(key string) (Value, bool) {
+func (c *GCache[K, V]) Get(key K) (value V, ok bool) {
 	if ele, ok := c.cache[key]; ok {
 		c.ll.MoveToFront(ele)
-		kv := ele.Value.(*entry)
+		kv := ele.Value.(*entry[K, V])
 		return kv.value, true
 	}
-	return nil, false
+	return value, false
+}
+
+// Peek gets the value from the cache by key without updating its recency.
+func (c *GCache[K, V]) Peek(key K) (value V, ok bool) {
+	if ele, ok := c.cache[key]; ok {
+		return ele.Value.(*entry[K, V]).value, true
+	}
+	return value, false
+}
+
+// Contains reports whether key is in the cache without updating its recency.
+func (c *GCache[K, V]) Contains(key K) bool {
+	_, ok := c.cache[key]
+	return ok
 }
 
-// evict evicts LRU entry from the cache.
-func (c *Cache) evict() {
+// RemoveOldest removes the LRU entry from the cache and returns it.
+// The onEvict callback is called for the removed entry.
+func (c *GCache[K, V]) RemoveOldest() (key K, value V, ok bool) {
 	ele := c.ll.Back()
-	if ele != nil {
-		c.ll.Remove(ele)
-		kv := ele.Value.(*entry)
-		delete(c.cache, kv.key)
-		c.size -= int64(len(kv.key)) + int64(kv.value.Len())
-		if c.onEvict != nil {
-			c.onEvict(kv.key, kv.value)
+	if ele == nil {
+		return key, value, false
+	}
+	kv := ele.Value.(*entry[K, V])
+	c.removeElement(ele, EvictRemoved)
+	return kv.key, kv.value, true
+}
+
+// Clear removes all the entries from the cache.
+// If evict is true, the onEvict callback is called for each of them from the least to the most recently used.
+func (c *GCache[K, V]) Clear(evict bool) {
+	if evict && c.onEvict != nil {
+		for c.ll.Len() > 0 {
+			c.removeElement(c.ll.Back(), EvictCleared)
 		}
+		return
+	}
+	c.ll.Init()
+	clear(c.cache)
+	c.size = 0
+}
+
+// Remove removes the entry of key from the cache.
+// The onEvict callback is called for the removed entry.
+func (c *GCache[K, V]) Remove(key K) {
+	if ele, ok := c.cache[key]; ok {
+		c.removeElement(ele, EvictRemoved)
+	}
+}
+
+func (c *GCache[K, V]) removeElement(ele *list.Element, reason EvictReason) {
+	c.ll.Remove(ele)
+	kv := ele.Value.(*entry[K, V])
+	delete(c.cache, kv.key)
+	c.size -= kv.size
+	if c.onEvict != nil {
+		c.onEvict(kv.key, kv.value, reason)
+	}
+}
+
+// evict evicts LRU entries until the cache fits its capacity.
+func (c *GCache[K, V]) evict() {
+	for c.capacity > 0 && c.size > c.capacity {
+		c.removeElement(c.ll.Back(), EvictCapacity)
 	}
 }
 
 // Set sets a value with a key in the cache.
-func (c *Cache) Set(key string, value Value) {
+// The onEvict callback is called for the value it replaces.
+//
+// An entry larger than the max entry size or the capacity is rejected rather than evicting the whole cache,
+// and the previous value of key, if any, is removed.
+func (c *GCache[K, V]) Set(key K, value V) {
+	size := c.sizeOfEntry(key, value)
+	if !c.admits(size) {
+		c.Remove(key)
+		return
+	}
 	if ele, ok := c.cache[key]; ok {
 		c.ll.MoveToFront(ele)
-		kv := ele.Value.(*entry)
-		c.size += int64(value.Len()) - int64(kv.value.Len())
-		kv.value = value
+		kv := ele.Value.(*entry[K, V])
+		old := kv.value
+		c.size += size - kv.size
+		kv.value, kv.size = value, size
+		if c.onEvict != nil {
+			c.onEvict(key, old, EvictReplaced)
+		}
 	} else {
-		ele := c.ll.PushFront(&entry{key, value})
+		ele := c.ll.PushFront(&entry[K, V]{key, value, size})
 		c.cache[key] = ele
-		c.size += int64(len(key)) + int64(value.Len())
+		c.size += size
 	}
-	for c.capacity > 0 && c.size > c.capacity {
-		c.evict()
+	c.evict()
+}
+
+// SetMaxEntrySize sets the maximum size of an entry admitted to the cache.
+// A size <= 0 means that the entries are only limited by the capacity.
+func (c *GCache[K, V]) SetMaxEntrySize(size int64) {
+	c.maxEntry = size
+}
+
+// admits reports whether an entry of size fits in the cache.
+func (c *GCache[K, V]) admits(size int64) bool {
+	return (c.maxEntry <= 0 || size <= c.maxEntry) && (c.capacity <= 0 || size <= c.capacity)
+}
+
+// sizeOfEntry returns the size of an entry.
+func (c *GCache[K, V]) sizeOfEntry(key K, value V) int64 {
+	if c.sizeOf == nil {
+		return 1
 	}
+	return c.sizeOf(key, value)
 }
 
 // Len returns the number of cache entries.
-func (c *Cache) Len() int {
+func (c *GCache[K, V]) Len() int {
 	return c.ll.Len()
 }
+
+// Size returns the size of the cache entries.
+func (c *GCache[K, V]) Size() int64 {
+	return c.size
+}
+
+// Resize changes the capacity of the cache, evicting LRU entries if it shrinks.
+func (c *GCache[K, V]) Resize(capacity int64) {
+	c.capacity = capacity
+	c.evict()
+}
+
+// All returns an iterator over the cache entries from the most to the least recently used.
+// It does not update the recency of the entries.
+func (c *GCache[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for ele := c.ll.Front(); ele != nil; ele = ele.Next() {
+			kv := ele.Value.(*entry[K, V])
+			if !yield(kv.key, kv.value) {
+				return
+			}
+		}
+	}
+}
+
+// Backward returns an iterator over the cache entries from the least to the most recently used.
+// It does not update the recency of the entries.
+func (c *GCache[K, V]) Backward() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for ele := c.ll.Back(); ele != nil; ele = ele.Prev() {
+			kv := ele.Value.(*entry[K, V])
+			if !yield(kv.key, kv.value) {
+				return
+			}
+		}
+	}
+}
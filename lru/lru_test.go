@@ -1,6 +1,7 @@
 package lru
 
 import (
+	"fmt"
 	"reflect"
 	"testing"
 )
@@ -64,3 +65,201 @@ func TestOnEvict(t *testing.T) {
 		t.Fatalf("cache onEvict callback failed (expected: %v, got: %v)", expected, keys)
 	}
 }
+
+func TestBackward(t *testing.T) {
+	lru := New(int64(0), nil)
+	lru.Set("k1", value("v1"))
+	lru.Set("k2", value("v2"))
+	lru.Set("k3", value("v3"))
+	lru.Get("k1")
+	keys := []string{}
+	for k := range lru.Backward() {
+		keys = append(keys, k)
+	}
+	expected := []string{"k2", "k3", "k1"}
+	if !reflect.DeepEqual(expected, keys) {
+		t.Fatalf("cache backward iteration failed (expected: %v, got: %v)", expected, keys)
+	}
+}
+
+func TestAll(t *testing.T) {
+	lru := New(int64(0), nil)
+	lru.Set("k1", value("v1"))
+	lru.Set("k2", value("v2"))
+	lru.Set("k3", value("v3"))
+	lru.Get("k1")
+	keys := []string{}
+	for k := range lru.All() {
+		keys = append(keys, k)
+	}
+	expected := []string{"k1", "k3", "k2"}
+	if !reflect.DeepEqual(expected, keys) {
+		t.Fatalf("cache iteration failed (expected: %v, got: %v)", expected, keys)
+	}
+}
+
+func TestPeek(t *testing.T) {
+	lru := New(int64(len("k1"+"v1")*2), nil)
+	lru.Set("k1", value("v1"))
+	lru.Set("k2", value("v2"))
+	if v, ok := lru.Peek("k1"); !ok || string(v.(value)) != "v1" {
+		t.Fatalf("cache peek k1=v1 failed")
+	}
+	if !lru.Contains("k1") || lru.Contains("k3") {
+		t.Fatalf("cache contains failed")
+	}
+	// Neither Peek nor Contains saved k1 from being the LRU entry.
+	lru.Set("k3", value("v3"))
+	if lru.Contains("k1") {
+		t.Fatalf("cache peek updated the recency of k1")
+	}
+}
+
+func TestRemoveOldest(t *testing.T) {
+	keys := []string{}
+	lru := New(int64(0), func(key string, value Value) {
+		keys = append(keys, key)
+	})
+	lru.Set("k1", value("v1"))
+	lru.Set("k2", value("v2"))
+	lru.Get("k1")
+	if k, v, ok := lru.RemoveOldest(); !ok || k != "k2" || string(v.(value)) != "v2" {
+		t.Fatalf("cache remove oldest failed (expected: %v, got: %v)", "k2", k)
+	}
+	lru.RemoveOldest()
+	if _, _, ok := lru.RemoveOldest(); ok || lru.Len() != 0 || lru.Size() != 0 {
+		t.Fatalf("cache remove oldest of an empty cache failed")
+	}
+	if expected := []string{"k2", "k1"}; !reflect.DeepEqual(expected, keys) {
+		t.Fatalf("cache remove callback failed (expected: %v, got: %v)", expected, keys)
+	}
+}
+
+func TestClear(t *testing.T) {
+	keys := []string{}
+	lru := New(int64(0), func(key string, value Value) {
+		keys = append(keys, key)
+	})
+	lru.Set("k1", value("v1"))
+	lru.Set("k2", value("v2"))
+	lru.Clear(false)
+	if lru.Len() != 0 || lru.Size() != 0 || lru.Contains("k1") || len(keys) != 0 {
+		t.Fatalf("cache clear failed")
+	}
+	lru.Set("k1", value("v1"))
+	lru.Set("k2", value("v2"))
+	lru.Clear(true)
+	if lru.Len() != 0 || lru.Size() != 0 {
+		t.Fatalf("cache clear failed")
+	}
+	if expected := []string{"k1", "k2"}; !reflect.DeepEqual(expected, keys) {
+		t.Fatalf("cache clear callback failed (expected: %v, got: %v)", expected, keys)
+	}
+}
+
+func TestRemove(t *testing.T) {
+	keys := []string{}
+	lru := New(int64(0), func(key string, value Value) {
+		keys = append(keys, key)
+	})
+	lru.Set("k1", value("v1"))
+	lru.Set("k2", value("v2"))
+	lru.Remove("k1")
+	lru.Remove("k3")
+	if _, ok := lru.Get("k1"); ok || lru.Len() != 1 || lru.size != int64(len("k2"+"v2")) {
+		t.Fatalf("cache remove k1 failed")
+	}
+	if expected := []string{"k1"}; !reflect.DeepEqual(expected, keys) {
+		t.Fatalf("cache remove callback failed (expected: %v, got: %v)", expected, keys)
+	}
+}
+
+func TestResize(t *testing.T) {
+	lru := New(int64(0), nil)
+	lru.Set("k1", value("v1"))
+	lru.Set("k2", value("v2"))
+	lru.Set("k3", value("v3"))
+	lru.Resize(int64(len("k3" + "v3")))
+	if _, ok := lru.Get("k3"); !ok || lru.Len() != 1 || lru.Size() != int64(len("k3"+"v3")) {
+		t.Fatalf("cache resize failed")
+	}
+}
+
+func TestGeneric(t *testing.T) {
+	keys := []int{}
+	lru := NewCache(int64(2), nil, func(key int, value string, reason EvictReason) {
+		keys = append(keys, key)
+	})
+	lru.Set(1, "v1")
+	lru.Set(2, "v2")
+	lru.Get(1)
+	lru.Set(3, "v3")
+	if v, ok := lru.Get(1); !ok || v != "v1" || lru.Len() != 2 || lru.Size() != 2 {
+		t.Fatalf("cache hit 1=v1 failed")
+	}
+	if expected := []int{2}; !reflect.DeepEqual(expected, keys) {
+		t.Fatalf("cache onEvict callback failed (expected: %v, got: %v)", expected, keys)
+	}
+}
+
+func TestAlias(t *testing.T) {
+	// Cache is the GCache of string keys and Value values, so both types can be used for the caches of New.
+	var lru *Cache = New(int64(0), nil)
+	var generic *GCache[string, Value] = lru
+	generic.Set("k1", value("v1"))
+	if v, ok := lru.Get("k1"); !ok || string(v.(value)) != "v1" {
+		t.Fatalf("cache alias k1=v1 failed")
+	}
+}
+
+func TestSizeOf(t *testing.T) {
+	lru := NewCache(int64(10), func(key string, value []byte) int64 {
+		return int64(len(value))
+	}, nil)
+	lru.Set("k1", make([]byte, 4))
+	lru.Set("k2", make([]byte, 4))
+	lru.Set("k1", make([]byte, 7))
+	if lru.Contains("k2") || lru.Size() != 7 {
+		t.Fatalf("cache size function failed (expected: %v, got: %v)", 7, lru.Size())
+	}
+}
+
+func TestEvictReason(t *testing.T) {
+	reasons := []string{}
+	lru := NewCache(int64(2), nil, func(key string, value string, reason EvictReason) {
+		reasons = append(reasons, fmt.Sprintf("%s=%s:%v", key, value, reason))
+	})
+	lru.Set("k1", "v1")
+	lru.Set("k1", "value1")
+	lru.Set("k2", "v2")
+	lru.Set("k3", "v3")
+	lru.Remove("k2")
+	lru.Set("k4", "v4")
+	lru.Clear(true)
+	expected := []string{"k1=v1:replaced", "k1=value1:capacity", "k2=v2:removed", "k3=v3:cleared", "k4=v4:cleared"}
+	if !reflect.DeepEqual(expected, reasons) {
+		t.Fatalf("cache onEvict reasons failed (expected: %v, got: %v)", expected, reasons)
+	}
+}
+
+func TestMaxEntrySize(t *testing.T) {
+	keys := []string{}
+	lru := New(int64(len("k1"+"v1")*3), func(key string, value Value) {
+		keys = append(keys, key)
+	})
+	lru.Set("k1", value("v1"))
+	lru.Set("k2", value("v2"))
+	// Larger than the capacity, k3 is rejected instead of flushing the cache.
+	lru.Set("k3", value("value3value3"))
+	if lru.Contains("k3") || lru.Len() != 2 || len(keys) != 0 {
+		t.Fatalf("cache rejection of k3 failed")
+	}
+	lru.SetMaxEntrySize(int64(len("k1" + "v1")))
+	lru.Set("k1", value("value1"))
+	if lru.Contains("k1") || lru.Len() != 1 {
+		t.Fatalf("cache rejection of k1 failed")
+	}
+	if expected := []string{"k1"}; !reflect.DeepEqual(expected, keys) {
+		t.Fatalf("cache onEvict callback failed (expected: %v, got: %v)", expected, keys)
+	}
+}
@@ -0,0 +1,217 @@
+package gocache
+
+import (
+	"container/list"
+	"hash/maphash"
+	"log"
+	"math"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// minStamps is the minimum number of access stamps a sampler numbers before renumbering its ghost entries.
+const minStamps = 1 << 10
+
+// defaultCurveScales are the capacities of the miss-ratio curve relative to the capacity of the group
+// when WithMissRatioCurve is given no capacities.
+var defaultCurveScales = []float64{0.125, 0.25, 0.5, 1, 2, 4, 8}
+
+// A MissRatioPoint is the estimated miss ratio of a group at a capacity.
+type MissRatioPoint struct {
+	Capacity  int64   // the capacity of the main cache in bytes
+	MissRatio float64 // the fraction of the Gets that would miss the main cache
+}
+
+// WithMissRatioCurve makes the group estimate the miss ratio it would have at other capacities of its main cache,
+// reported in Stats.MissRatioCurve, so that it can be sized from its actual traffic.
+//
+// The estimation samples the fraction rate (e.g. 0.01) of the keys by their hashes, and simulates an LRU cache
+// of their sizes, len(key) + len(value), with ghost entries holding no values (SHARDS).
+// The memory it uses is about rate times the number of keys fitting in the largest capacity.
+// The capacities default to 1/8 to 8 times the capacity the group is created with, whatever the order of the options.
+// They stay the same when the capacity changes later, e.g. by SetCapacity or an Adaptive.
+// A group created with no limit has no capacity to scale, so it needs explicit capacities to estimate a curve.
+func WithMissRatioCurve(rate float64, capacities ...int64) GroupOption {
+	return func(g *Group) {
+		// The default capacities are set by defaultCurve once every option is applied.
+		g.mrc = newSampler(rate, capacities)
+	}
+}
+
+// defaultCurve returns a sampler of rate for the default capacities of the curve of g, see WithMissRatioCurve,
+// or nil if g has no limit.
+func (g *Group) defaultCurve(rate float64) *sampler {
+	if g.mainCache.capacity <= 0 {
+		log.Printf("[gocache] miss-ratio curve ignored for group=%s: no capacities for a group with no limit", g.name)
+		return nil
+	}
+	var capacities []int64
+	for _, scale := range defaultCurveScales {
+		capacities = append(capacities, int64(scale*float64(g.mainCache.capacity)))
+	}
+	return newSampler(rate, capacities)
+}
+
+// sample records a Get of key for the miss-ratio curve of the group.
+// Only the Gets of the keys cached by the group are sampled, i.e. not the ones loaded from peers.
+func (g *Group) sample(key string, value ByteView) {
+	if g.mrc != nil {
+		g.mrc.access(key, entrySize(key, value))
+	}
+}
+
+// A sampler estimates a miss-ratio curve from a spatially sampled LRU stack of ghost entries.
+type sampler struct {
+	seed       maphash.Seed
+	rate       float64
+	threshold  uint64       // the keys whose hashes are <= threshold are sampled
+	capacities []int64      // the capacities of the curve in ascending order
+	total      atomic.Int64 // all the accesses, sampled or not
+
+	mu       sync.Mutex
+	ll       *list.List               // the ghost entries, the most recently accessed first
+	entries  map[string]*list.Element // the key to ghost entry mapping
+	size     int64                    // the total size of the ghost entries
+	sizes    fenwick                  // the sizes of the ghost entries by the stamps of their last accesses
+	stamp    int                      // the stamp of the last access
+	hits     []int64                  // hits[i] are the sampled accesses that hit at capacities[i]
+	accesses int64                    // the sampled accesses
+}
+
+// A ghost is a sampled key with the size of its entry.
+type ghost struct {
+	key   string
+	size  int64
+	stamp int // the stamp of its last access
+}
+
+// The constructor of sampler.
+func newSampler(rate float64, capacities []int64) *sampler {
+	rate = min(max(rate, math.SmallestNonzeroFloat64), 1)
+	capacities = slices.Sorted(slices.Values(capacities))
+	return &sampler{
+		seed:       maphash.MakeSeed(),
+		rate:       rate,
+		threshold:  uint64(rate * math.MaxUint64),
+		capacities: capacities,
+		ll:         list.New(),
+		entries:    make(map[string]*list.Element),
+		sizes:      make(fenwick, minStamps+1),
+		hits:       make([]int64, len(capacities)),
+	}
+}
+
+// access records a Get of key whose entry has size bytes, if key is sampled.
+func (s *sampler) access(key string, size int64) {
+	s.total.Add(1)
+	if s.rate < 1 && maphash.String(s.seed, key) > s.threshold {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.accesses++
+	var g *ghost
+	if ele, ok := s.entries[key]; ok {
+		g = ele.Value.(*ghost)
+		s.hit(g)
+		s.sizes.add(g.stamp, -g.size)
+		s.size += size - g.size
+		g.size = size
+		s.ll.MoveToFront(ele)
+	} else {
+		g = &ghost{key: key, size: size}
+		s.entries[key] = s.ll.PushFront(g)
+		s.size += size
+	}
+	s.restamp(g)
+	s.trim()
+}
+
+// hit counts the access of a ghost entry at the capacities its stack distance fits in.
+// The stack distance is the size of the entries accessed since the last access of g, including its own,
+// scaled by the sampling rate.
+func (s *sampler) hit(g *ghost) {
+	distance := s.size - s.sizes.sum(g.stamp-1)
+	for i, capacity := range s.capacities {
+		if float64(distance)/s.rate <= float64(capacity) {
+			s.hits[i]++
+		}
+	}
+}
+
+// restamp stamps the access of g, the most recently accessed ghost entry, whose size is not in s.sizes.
+// When the stamps run out, the other ghost entries are renumbered from 1 in the order of their accesses.
+func (s *sampler) restamp(g *ghost) {
+	if s.stamp+1 >= len(s.sizes) {
+		s.sizes = make(fenwick, max(2*s.ll.Len(), minStamps)+1)
+		s.stamp = 0
+		for e := s.ll.Back(); e != s.ll.Front(); e = e.Prev() {
+			s.stamp++
+			other := e.Value.(*ghost)
+			other.stamp = s.stamp
+			s.sizes.add(other.stamp, other.size)
+		}
+	}
+	s.stamp++
+	g.stamp = s.stamp
+	s.sizes.add(g.stamp, g.size)
+}
+
+// trim removes the ghost entries beyond the largest capacity, which would miss at every capacity.
+func (s *sampler) trim() {
+	largest := s.capacities[len(s.capacities)-1]
+	for float64(s.size)/s.rate > float64(largest) {
+		ele := s.ll.Back()
+		if ele == s.ll.Front() {
+			return
+		}
+		g := s.ll.Remove(ele).(*ghost)
+		delete(s.entries, g.key)
+		s.sizes.add(g.stamp, -g.size)
+		s.size -= g.size
+	}
+}
+
+// curve returns the estimated miss ratios at the capacities; nil if no access has been sampled yet.
+//
+// A few frequent keys make the sampled accesses deviate from rate times all the accesses.
+// As these keys are mostly hits at every capacity, the difference is counted as such (SHARDS-adj).
+func (s *sampler) curve() []MissRatioPoint {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.accesses == 0 {
+		return nil
+	}
+	expected := float64(s.total.Load()) * s.rate
+	adjustment := expected - float64(s.accesses)
+	points := make([]MissRatioPoint, len(s.capacities))
+	for i, capacity := range s.capacities {
+		hits := min(max(float64(s.hits[i])+adjustment, 0), expected)
+		points[i] = MissRatioPoint{
+			Capacity:  capacity,
+			MissRatio: 1 - hits/expected,
+		}
+	}
+	return points
+}
+
+// A fenwick is a Fenwick tree (binary indexed tree) of the sums of its values, indexed from 1 to len - 1,
+// so that the stack distance of an access is a prefix sum instead of a walk of the ghost entries.
+type fenwick []int64
+
+// add adds delta to the value at i.
+func (f fenwick) add(i int, delta int64) {
+	for ; i < len(f); i += i & -i {
+		f[i] += delta
+	}
+}
+
+// sum returns the sum of the values from 1 to i.
+func (f fenwick) sum(i int) int64 {
+	var sum int64
+	for ; i > 0; i -= i & -i {
+		sum += f[i]
+	}
+	return sum
+}
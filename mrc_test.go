@@ -0,0 +1,133 @@
+package gocache
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"strings"
+	"testing"
+)
+
+func TestMissRatioCurve(t *testing.T) {
+	// Every entry is 4 + 4 bytes, e.g. key=k001 and value=k001.
+	g := NewGroup("mrc", 8*10, GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte(key), nil
+		}), WithMissRatioCurve(1, 8*5, 8*10, 8*20))
+	defer g.Close()
+
+	if curve := g.Stats().MissRatioCurve; curve != nil {
+		t.Fatalf("group estimated a curve without Gets: %v", curve)
+	}
+	// Cycling over 10 keys always misses an LRU cache of 5 entries, and only misses the first time with 10.
+	for range 10 {
+		for i := range 10 {
+			g.Get(fmt.Sprintf("k%03d", i))
+		}
+	}
+	expected := []MissRatioPoint{{8 * 5, 1}, {8 * 10, 0.1}, {8 * 20, 0.1}}
+	curve := g.Stats().MissRatioCurve
+	for i := range expected {
+		if i >= len(curve) || curve[i].Capacity != expected[i].Capacity ||
+			math.Abs(curve[i].MissRatio-expected[i].MissRatio) > 1e-9 {
+			t.Fatalf("group miss-ratio curve failed (expected: %v, got: %v)", expected, curve)
+		}
+	}
+}
+
+func TestMissRatioCurveSampled(t *testing.T) {
+	// The curve estimated from 10% of the keys is close to the miss ratio of an LRU cache of the same capacity.
+	// Every entry is 5 + 5 bytes, e.g. key=k0001 and value=k0001.
+	const capacity = 10 * 1000
+	g := NewGroup("mrc_sampled", capacity, GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte(key), nil
+		}), WithMissRatioCurve(0.1), WithShards(1))
+	defer g.Close()
+
+	zipf := rand.NewZipf(rand.New(rand.NewPCG(1, 2)), 1.01, 1, 9999)
+	for range 100000 {
+		g.Get(fmt.Sprintf("k%04d", zipf.Uint64()))
+	}
+	stats := g.Stats()
+	missRatio := 1 - float64(stats.Hits)/float64(stats.Gets)
+	for _, point := range stats.MissRatioCurve {
+		if point.Capacity == capacity && math.Abs(point.MissRatio-missRatio) > 0.05 {
+			t.Fatalf("group miss-ratio estimate failed (expected: %v, got: %v)", missRatio, point.MissRatio)
+		}
+	}
+	if n := len(stats.MissRatioCurve); n != len(defaultCurveScales) {
+		t.Fatalf("group miss-ratio curve has %v points (expected: %v)", n, len(defaultCurveScales))
+	}
+}
+
+func TestMissRatioCurveDefaults(t *testing.T) {
+	getter := GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	})
+	g := NewGroup("mrc_defaults", 1<<10, getter, WithMissRatioCurve(1))
+	unlimited := NewGroup("mrc_unlimited", 0, getter, WithMissRatioCurve(1))
+	defer g.Close()
+	defer unlimited.Close()
+
+	g.Get("key")
+	g.SetCapacity(1 << 20)
+	curve := g.Stats().MissRatioCurve
+	if len(curve) != len(defaultCurveScales) || curve[0].Capacity != 1<<7 || curve[len(curve)-1].Capacity != 1<<13 {
+		t.Fatalf("group miss-ratio curve default capacities failed (got: %v)", curve)
+	}
+	unlimited.Get("key")
+	if curve := unlimited.Stats().MissRatioCurve; curve != nil {
+		t.Fatalf("group with no limit estimated a curve at default capacities (got: %v)", curve)
+	}
+}
+
+// remotePeers picks the owner group for the keys starting with "remote".
+type remotePeers struct {
+	owner *Group
+}
+
+func (p remotePeers) PickPeer(key string) (Peer, bool) {
+	return localPeer{p.owner}, strings.HasPrefix(key, "remote")
+}
+
+func TestMissRatioCurveLocal(t *testing.T) {
+	getter := GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	})
+	owner := NewGroup("mrc_owner", 0, getter)
+	g := NewGroup("mrc_local", 8*10, getter, WithMissRatioCurve(1, 8*10))
+	defer owner.Close()
+	defer g.Close()
+	g.RegisterPeers(remotePeers{owner})
+
+	for range 10 {
+		g.Get("remote")
+	}
+	if curve := g.Stats().MissRatioCurve; curve != nil {
+		t.Fatalf("group sampled the values loaded from peers: %v", curve)
+	}
+	g.Get("k001")
+	g.Get("k001")
+	if curve := g.Stats().MissRatioCurve; len(curve) != 1 || curve[0].MissRatio != 0.5 {
+		t.Fatalf("group failed to sample the local Gets: %v", curve)
+	}
+}
+
+func TestSamplerRenumber(t *testing.T) {
+	// Cycling over 10 keys of 8 bytes makes the sampler renumber its stamps many times.
+	s := newSampler(1, []int64{8 * 9, 8 * 10})
+	for range 1000 {
+		for i := range 10 {
+			s.access(fmt.Sprintf("k%03d", i), 8)
+		}
+	}
+	expected := []MissRatioPoint{{8 * 9, 1}, {8 * 10, 0.001}}
+	curve := s.curve()
+	for i := range expected {
+		if i >= len(curve) || curve[i].Capacity != expected[i].Capacity ||
+			math.Abs(curve[i].MissRatio-expected[i].MissRatio) > 1e-9 {
+			t.Fatalf("sampler stack distances failed (expected: %v, got: %v)", expected, curve)
+		}
+	}
+}
@@ -11,3 +11,10 @@ type PeerPicker interface {
 type Peer interface {
 	Get(in *pb.Request, out *pb.Response) error
 }
+
+// A PeerSetter is a Peer that is also able to store data for the given group and key,
+// e.g. the values side-loaded by a getter, see LoadResult.Extra.
+type PeerSetter interface {
+	Peer
+	Set(in *pb.SetRequest) error
+}
@@ -0,0 +1,131 @@
+package gocache
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrPinnedCapacity is returned by Pin when the value does not fit in the pinned capacity of the group.
+var ErrPinnedCapacity = errors.New("gocache: pinned capacity exceeded")
+
+// A PinGetter is a Getter that can ask for the loaded values to be pinned, see Group.Pin.
+// If the getter of a group implements PinGetter, the group loads the values with GetPinned.
+type PinGetter interface {
+	Getter
+	GetPinned(key string) (value []byte, pin bool, err error)
+}
+
+// pinned holds the pinned entries of a group, out of the reach of the eviction of the main cache.
+type pinned struct {
+	mu       sync.RWMutex
+	capacity int64 // the maximum size of the pinned entries; capacity <= 0 means no limit
+	size     int64
+	entries  map[string]ByteView
+	n        atomic.Int64 // the number of entries, to skip the lock when there are none
+}
+
+// WithPinnedCapacity limits the size of the pinned entries of the group, len(key) + len(value), to capacity bytes.
+// The pinned entries are counted against this capacity instead of the one of the main cache.
+// Without it, the pinned entries are not limited.
+func WithPinnedCapacity(capacity int64) GroupOption {
+	return func(g *Group) {
+		g.pinned.capacity = capacity
+	}
+}
+
+// Pin loads the value of key if needed and keeps it in memory until Unpin, excluded from eviction.
+// It returns ErrPinnedCapacity if the value does not fit in the pinned capacity.
+func (g *Group) Pin(key string) error {
+	value, err := g.get(key)
+	if err != nil {
+		return err
+	}
+	return g.pin(key, value)
+}
+
+// Unpin makes the value of key evictable again, moving it back to the main cache.
+func (g *Group) Unpin(key string) {
+	p := &g.pinned
+	p.mu.Lock()
+	value, ok := p.entries[key]
+	if ok {
+		delete(p.entries, key)
+		p.size -= entrySize(key, value)
+		p.n.Add(-1)
+	}
+	p.mu.Unlock()
+	if ok {
+		g.populateCache(key, value)
+	}
+}
+
+// pin moves a value to the pinned entries.
+func (g *Group) pin(key string, value ByteView) error {
+	p := &g.pinned
+	size := entrySize(key, value)
+	p.mu.Lock()
+	old, ok := p.entries[key]
+	if ok {
+		size -= entrySize(key, old)
+	}
+	if p.capacity > 0 && p.size+size > p.capacity {
+		p.mu.Unlock()
+		return ErrPinnedCapacity
+	}
+	if p.entries == nil {
+		p.entries = make(map[string]ByteView)
+	}
+	p.entries[key] = value
+	p.size += size
+	if !ok {
+		p.n.Add(1)
+	}
+	p.mu.Unlock()
+	g.mainCache.remove(key, EvictPinned)
+	if g.largeObjects != nil {
+		g.largeObjects.remove(key, EvictPinned)
+	}
+	return nil
+}
+
+// getPinned gets the value of key from the pinned entries.
+func (g *Group) getPinned(key string) (ByteView, bool) {
+	p := &g.pinned
+	if p.n.Load() == 0 {
+		return ByteView{}, false
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	value, ok := p.entries[key]
+	return value, ok
+}
+
+// populatePinned pins the value loaded by a PinGetter, or caches it as usual if it does not fit.
+func (g *Group) populatePinned(key string, value ByteView) {
+	if err := g.pin(key, value); err != nil {
+		log.Printf("[gocache] failed to pin key=%s: %v", key, err)
+		g.populateCache(key, value)
+	}
+}
+
+// pinnedItems returns a copy of the pinned entries.
+func (g *Group) pinnedItems() []item {
+	p := &g.pinned
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	items := make([]item, 0, len(p.entries))
+	for k, v := range p.entries {
+		items = append(items, item{key: k, value: v})
+	}
+	return items
+}
+
+// pinnedStats returns the number and the size of the pinned entries.
+func (g *Group) pinnedStats() (int64, int64) {
+	p := &g.pinned
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return int64(len(p.entries)), p.size
+}
@@ -0,0 +1,81 @@
+package gocache
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// pinGetter pins the keys starting with "config".
+type pinGetter struct{}
+
+func (pinGetter) Get(key string) ([]byte, error) {
+	return []byte(key), nil
+}
+
+func (pinGetter) GetPinned(key string) ([]byte, bool, error) {
+	return []byte(key), strings.HasPrefix(key, "config"), nil
+}
+
+func TestPin(t *testing.T) {
+	// Every entry is 4 + 4 bytes, e.g. key=k001 and value=k001.
+	reasons := map[string]EvictReason{}
+	g := NewGroup("pin", 8*4, GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte(key), nil
+		}), WithPinnedCapacity(8*2), WithEvictHook(
+		func(key string, value ByteView, reason EvictReason) {
+			reasons[key] = reason
+		}))
+	defer g.Close()
+
+	g.Get("p001")
+	if err := g.Pin("p001"); err != nil {
+		t.Fatalf("group failed to pin p001: %v", err)
+	}
+	if reason, ok := reasons["p001"]; !ok || reason != EvictPinned {
+		t.Fatalf("group reported the wrong reason for pinning p001 (reason: %v)", reason)
+	}
+	g.Pin("p002")
+	if err := g.Pin("p003"); !errors.Is(err, ErrPinnedCapacity) {
+		t.Fatalf("group pinned beyond its pinned capacity (err: %v)", err)
+	}
+	for i := range 100 {
+		g.Get(fmt.Sprintf("k%03d", i))
+	}
+	if v, ok := g.lookupCache("p001"); !ok || v.String() != "p001" {
+		t.Fatalf("group evicted the pinned key p001")
+	}
+	if stats := g.Stats(); stats.Pinned != 2 || stats.PinnedSize != 16 || g.mainCache.len() != 4 {
+		t.Fatalf("group pinned stats failed (pinned: %v, size: %v)", stats.Pinned, stats.PinnedSize)
+	}
+
+	g.Unpin("p001")
+	if _, ok := g.mainCache.get("p001"); !ok || g.Stats().Pinned != 1 {
+		t.Fatalf("group failed to unpin p001")
+	}
+	for i := range 100 {
+		g.Get(fmt.Sprintf("k%03d", i))
+	}
+	if _, ok := g.lookupCache("p001"); ok {
+		t.Fatalf("group failed to evict the unpinned key p001")
+	}
+}
+
+func TestPinGetter(t *testing.T) {
+	g := NewGroup("pin_getter", 8*4, pinGetter{})
+	defer g.Close()
+
+	g.Get("config")
+	g.Get("k001")
+	for i := range 100 {
+		g.Get(fmt.Sprintf("k%03d", i))
+	}
+	if _, ok := g.getPinned("config"); !ok {
+		t.Fatalf("group failed to pin the value of the getter")
+	}
+	if _, ok := g.getPinned("k001"); ok {
+		t.Fatalf("group pinned a value not pinned by the getter")
+	}
+}
@@ -0,0 +1,349 @@
+package gocache
+
+import (
+	"encoding/binary"
+	"errors"
+	"log"
+	"time"
+
+	pb "github.com/thezbm/gocache/gocachepb"
+)
+
+// A LoadResult is a value loaded by a ResultGetter with its metadata, which is stored with the entry,
+// carried to the peers, and returned by Group.GetResult.
+// The metadata is not counted in the size of the entry, and should be kept small.
+type LoadResult struct {
+	Value       ByteView          // the value, e.g. BytesView(b) or StringView(s)
+	TTL         time.Duration     // (optional) how long the value stays cached; <= 0 means until it is evicted
+	ContentType string            // (optional) the media type of the value, e.g. "application/json"
+	Version     string            // (optional) the version of the value, e.g. an ETag
+	NoCache     bool              // whether the value is returned without being cached
+	Pin         bool              // whether the value is pinned, see Group.Pin
+	Metadata    map[string]string // (optional) arbitrary metadata of the value
+
+	// (optional) the values of other keys loaded along with this one, e.g. the rest of a row set.
+	// They are cached by the group if it owns their keys, and pushed to their owners otherwise
+	// if the group has WithPeerPush.
+	// Their own Extra is ignored.
+	Extra map[string]LoadResult
+}
+
+// A ResultGetter is a Getter that can load the values with their metadata.
+// If the getter of a group implements ResultGetter, the group loads the values with GetResult.
+type ResultGetter interface {
+	Getter
+	GetResult(key string) (LoadResult, error)
+}
+
+// A ResultGetterFunc implements ResultGetter with a function.
+type ResultGetterFunc func(key string) (LoadResult, error)
+
+func (f ResultGetterFunc) Get(key string) ([]byte, error) {
+	r, err := f(key)
+	return r.Value.ByteSlice(), err
+}
+
+func (f ResultGetterFunc) GetResult(key string) (LoadResult, error) {
+	return f(key)
+}
+
+// GetResult gets the value for the given key from the cache with its metadata.
+// The TTL of the result is the remaining time to live of the value.
+func (g *Group) GetResult(key string) (LoadResult, error) {
+	value, err := g.Get(key)
+	if err != nil {
+		return LoadResult{}, err
+	}
+	return value.result(), nil
+}
+
+// valueMeta is the metadata of a value loaded by a ResultGetter.
+type valueMeta struct {
+	expires     time.Time // the zero time means no expiry
+	contentType string
+	version     string
+	noCache     bool
+	metadata    map[string]string
+}
+
+// newValueMeta returns the metadata of a LoadResult; nil if it has none.
+func newValueMeta(r LoadResult) *valueMeta {
+	m := &valueMeta{
+		contentType: r.ContentType,
+		version:     r.Version,
+		noCache:     r.NoCache,
+		metadata:    r.Metadata,
+	}
+	if r.TTL > 0 {
+		m.expires = time.Now().Add(r.TTL)
+	}
+	if m.expires.IsZero() && m.contentType == "" && m.version == "" && !m.noCache && len(m.metadata) == 0 {
+		return nil
+	}
+	return m
+}
+
+// responseMeta returns the metadata of the value in a peer's response; nil if it has none.
+func responseMeta(resp *pb.Response) *valueMeta {
+	return newValueMeta(LoadResult{
+		TTL:         time.Duration(resp.GetTtlMs()) * time.Millisecond,
+		ContentType: resp.GetContentType(),
+		Version:     resp.GetVersion(),
+		NoCache:     resp.GetNoCache(),
+		Metadata:    resp.GetMetadata(),
+	})
+}
+
+// expired returns whether the value has expired.
+func (b ByteView) expired() bool {
+	return b.meta != nil && !b.meta.expires.IsZero() && !time.Now().Before(b.meta.expires)
+}
+
+// ttl returns the remaining time to live of the value; 0 means no expiry.
+func (b ByteView) ttl() time.Duration {
+	if b.meta == nil || b.meta.expires.IsZero() {
+		return 0
+	}
+	// An expired value is not served, so its ttl is never 0.
+	return max(time.Until(b.meta.expires), 1)
+}
+
+// result returns the LoadResult of the value.
+func (b ByteView) result() LoadResult {
+	r := LoadResult{Value: ByteView{bytes: b.bytes, str: b.str, c: b.c}, TTL: b.ttl()}
+	if b.meta != nil {
+		r.ContentType = b.meta.contentType
+		r.Version = b.meta.version
+		r.NoCache = b.meta.noCache
+		r.Metadata = b.meta.metadata
+	}
+	return r
+}
+
+// expire removes the expired value of key from the group.
+// A value loaded again by a concurrent Get in the meantime is kept.
+func (g *Group) expire(key string) {
+	p := &g.pinned
+	p.mu.Lock()
+	if value, ok := p.entries[key]; ok && value.expired() {
+		delete(p.entries, key)
+		p.size -= entrySize(key, value)
+		p.n.Add(-1)
+	}
+	p.mu.Unlock()
+	g.mainCache.removeExpired(key)
+	if g.largeObjects != nil {
+		g.largeObjects.removeExpired(key)
+	}
+	log.Printf("[gocache] expire key=%s", key)
+}
+
+// appendMeta appends the encoding of m to b: varint expiry in Unix nanoseconds (0 means no expiry),
+// the content type and the version, then uvarint number of metadata pairs and the pairs,
+// with every string as uvarint length and bytes.
+func appendMeta(b []byte, m *valueMeta) []byte {
+	var expires int64
+	if !m.expires.IsZero() {
+		expires = m.expires.UnixNano()
+	}
+	b = binary.AppendVarint(b, expires)
+	b = appendString(b, m.contentType)
+	b = appendString(b, m.version)
+	b = binary.AppendUvarint(b, uint64(len(m.metadata)))
+	for k, v := range m.metadata {
+		b = appendString(b, k)
+		b = appendString(b, v)
+	}
+	return b
+}
+
+// parseMeta parses the metadata encoded by appendMeta; nil if b is malformed.
+func parseMeta(b []byte) *valueMeta {
+	m := &valueMeta{}
+	expires, n := binary.Varint(b)
+	if n <= 0 {
+		return nil
+	}
+	b = b[n:]
+	if expires != 0 {
+		m.expires = time.Unix(0, expires)
+	}
+	var ok bool
+	if m.contentType, b, ok = parseString(b); !ok {
+		return nil
+	}
+	if m.version, b, ok = parseString(b); !ok {
+		return nil
+	}
+	count, n := binary.Uvarint(b)
+	if n <= 0 || count > uint64(len(b)) {
+		return nil
+	}
+	b = b[n:]
+	if count > 0 {
+		m.metadata = make(map[string]string, count)
+	}
+	for range count {
+		var k, v string
+		if k, b, ok = parseString(b); !ok {
+			return nil
+		}
+		if v, b, ok = parseString(b); !ok {
+			return nil
+		}
+		m.metadata[k] = v
+	}
+	return m
+}
+
+func appendString(b []byte, s string) []byte {
+	b = binary.AppendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+func parseString(b []byte) (string, []byte, bool) {
+	l, n := binary.Uvarint(b)
+	if n <= 0 || l > uint64(len(b)-n) {
+		return "", nil, false
+	}
+	return string(b[n : n+int(l)]), b[n+int(l):], true
+}
+
+// WithPeerPush makes the group push the side-loaded values of keys owned by peers to them,
+// see LoadResult.Extra, and accept the values pushed by its peers.
+// The pushes are not authenticated, so the peer handler must only be reachable by the peers.
+func WithPeerPush() GroupOption {
+	return func(g *Group) {
+		g.peerPush = true
+	}
+}
+
+// Errors returned by setFromPeer.
+var (
+	errPushDisabled = errors.New("peer push is disabled")
+	errNotOwner     = errors.New("key is owned by another peer")
+)
+
+// sideLoad populates the values loaded along with the value of key where their keys are owned:
+// in the cache of the group, or in the owning peers in the background.
+func (g *Group) sideLoad(key string, extra map[string]LoadResult) {
+	for k, r := range extra {
+		if k == "" || k == key || r.NoCache {
+			continue
+		}
+		if g.peers != nil {
+			if peer, ok := g.peers.PickPeer(k); ok {
+				if setter, ok := peer.(PeerSetter); ok && g.peerPush {
+					g.queuePush(setter, k, r)
+				}
+				continue
+			}
+		}
+		value := r.Value
+		value.meta = newValueMeta(r)
+		value = g.compress(value)
+		if r.Pin {
+			g.populatePinned(k, value)
+		} else {
+			g.populateCache(k, value)
+		}
+	}
+	log.Printf("[gocache] side-load %d keys with key=%s", len(extra), key)
+}
+
+// setRequest returns the request storing a side-loaded value in a peer.
+func (g *Group) setRequest(key string, r LoadResult) *pb.SetRequest {
+	req := &pb.SetRequest{
+		Group:       g.name,
+		Key:         key,
+		Value:       r.Value.data(),
+		ContentType: r.ContentType,
+		Version:     r.Version,
+		Metadata:    r.Metadata,
+	}
+	if r.TTL > 0 {
+		req.TtlMs = max(r.TTL.Milliseconds(), 1)
+	}
+	return req
+}
+
+// pushQueueSize is the number of side-loaded values that can wait to be pushed to their owners.
+// The values side-loaded while the queue is full are not pushed.
+const pushQueueSize = 1024
+
+// A push is a side-loaded value waiting to be stored in the peer owning its key.
+type push struct {
+	peer PeerSetter
+	req  *pb.SetRequest
+}
+
+// queuePush queues a side-loaded value to be pushed to the peer owning its key by pushLoop.
+func (g *Group) queuePush(peer PeerSetter, key string, r LoadResult) {
+	select {
+	case g.pushes <- push{peer: peer, req: g.setRequest(key, r)}:
+	default:
+		log.Printf("[gocache] drop push of key=%s: the push queue is full", key)
+	}
+}
+
+// pushLoop pushes the queued values to their owners one after another until the group is closed.
+// The values still queued then are dropped, as they are only a hint to their owners.
+func (g *Group) pushLoop() {
+	defer g.wg.Done()
+	for {
+		select {
+		case p := <-g.pushes:
+			if err := p.peer.Set(p.req); err != nil {
+				log.Printf("[gocache] failed to push key=%s to peer: %v", p.req.GetKey(), err)
+			}
+		case <-g.done:
+			return
+		}
+	}
+}
+
+// maxPushSize is the maximum size of a value pushed by a peer to a group whose entries have no size limit.
+const maxPushSize = 64 << 20
+
+// pushMetaSize is the room left for the key and the metadata of a pushed value in the body of its request.
+const pushMetaSize = 64 << 10
+
+// pushLimit returns the maximum size of the body of a request pushing a value to g:
+// the largest entry the group may cache, see entryLimit and AdmitLarge, with room for its metadata.
+func (g *Group) pushLimit() int64 {
+	limit := g.entryLimit()
+	if limit > 0 && g.admission == AdmitLarge && g.largeObjects != nil {
+		if capacity := g.largeObjects.getCapacity(); capacity <= 0 {
+			limit = 0
+		} else {
+			limit = max(limit, capacity)
+		}
+	}
+	if limit <= 0 {
+		limit = maxPushSize
+	}
+	return limit + pushMetaSize
+}
+
+// setFromPeer caches a value pushed by a peer.
+// It is rejected unless the group has WithPeerPush and owns the key.
+func (g *Group) setFromPeer(req *pb.SetRequest) error {
+	if !g.peerPush {
+		return errPushDisabled
+	}
+	if g.peers != nil {
+		if _, ok := g.peers.PickPeer(req.GetKey()); ok {
+			return errNotOwner
+		}
+	}
+	value := ByteView{bytes: req.GetValue()}
+	value.meta = newValueMeta(LoadResult{
+		TTL:         time.Duration(req.GetTtlMs()) * time.Millisecond,
+		ContentType: req.GetContentType(),
+		Version:     req.GetVersion(),
+		Metadata:    req.GetMetadata(),
+	})
+	g.populateCache(req.GetKey(), g.compress(value))
+	log.Printf("[gocache] set by peer with key=%s", req.GetKey())
+	return nil
+}
@@ -0,0 +1,288 @@
+package gocache
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	pb "github.com/thezbm/gocache/gocachepb"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// resultGetter loads the values with metadata, keeping the keys starting with "live" for 20ms
+// and not caching the ones starting with "nocache".
+func resultGetter(loads map[string]int) ResultGetterFunc {
+	return func(key string) (LoadResult, error) {
+		loads[key]++
+		r := LoadResult{
+			Value:       StringView(key),
+			ContentType: "text/plain",
+			Version:     "v1",
+			NoCache:     strings.HasPrefix(key, "nocache"),
+			Metadata:    map[string]string{"Owner": "test"},
+		}
+		if strings.HasPrefix(key, "live") {
+			r.TTL = 20 * time.Millisecond
+		}
+		return r, nil
+	}
+}
+
+func TestLoadResult(t *testing.T) {
+	loads := map[string]int{}
+	var expired []string
+	g := NewGroup("load_result", 1<<10, resultGetter(loads), WithEvictHook(
+		func(key string, value ByteView, reason EvictReason) {
+			if reason == EvictExpired {
+				expired = append(expired, key)
+			}
+		}))
+	defer g.Close()
+
+	r, err := g.GetResult("key")
+	if err != nil || r.Value.String() != "key" || r.ContentType != "text/plain" || r.Version != "v1" ||
+		r.TTL != 0 || r.Metadata["Owner"] != "test" {
+		t.Fatalf("group GetResult failed (got: %+v, err: %v)", r, err)
+	}
+	g.GetResult("key")
+	if loads["key"] != 1 {
+		t.Fatalf("group failed to cache the result (loads: %v)", loads["key"])
+	}
+
+	g.Get("nocache")
+	g.Get("nocache")
+	if loads["nocache"] != 2 {
+		t.Fatalf("group cached a NoCache result (loads: %v)", loads["nocache"])
+	}
+
+	if r, _ := g.GetResult("live"); r.TTL <= 0 || r.TTL > 20*time.Millisecond {
+		t.Fatalf("group TTL failed (got: %v)", r.TTL)
+	}
+	g.Get("live")
+	time.Sleep(30 * time.Millisecond)
+	g.Get("live")
+	if loads["live"] != 2 || !reflect.DeepEqual([]string{"live"}, expired) {
+		t.Fatalf("group failed to expire the value (loads: %v, expired: %v)", loads["live"], expired)
+	}
+}
+
+func TestExpireReloaded(t *testing.T) {
+	loads := map[string]int{}
+	g := NewGroup("expire_reloaded", 1<<10, resultGetter(loads))
+	defer g.Close()
+
+	g.Get("live")
+	time.Sleep(30 * time.Millisecond)
+	// A concurrent Get loaded the value again after this one found it expired.
+	g.Get("live")
+	g.expire("live")
+	g.Get("live")
+	if loads["live"] != 2 {
+		t.Fatalf("group expired a reloaded value (loads: %v)", loads["live"])
+	}
+}
+
+func TestLoadResultPin(t *testing.T) {
+	g := NewGroup("load_result_pin", 1<<10, ResultGetterFunc(
+		func(key string) (LoadResult, error) {
+			return LoadResult{Value: StringView(key), Pin: true}, nil
+		}))
+	defer g.Close()
+
+	g.Get("key")
+	if _, ok := g.getPinned("key"); !ok {
+		t.Fatalf("group failed to pin the result")
+	}
+}
+
+func TestResponseMeta(t *testing.T) {
+	g := NewGroup("response_meta", 1<<10, resultGetter(map[string]int{}))
+	defer g.Close()
+
+	resp, err := g.response("live", "")
+	if err != nil {
+		t.Fatalf("group response failed: %v", err)
+	}
+	b, _ := proto.Marshal(resp)
+	out := &pb.Response{}
+	if err := proto.Unmarshal(b, out); err != nil {
+		t.Fatalf("response decoding failed: %v", err)
+	}
+	r := ByteView{bytes: out.Value, meta: responseMeta(out)}.result()
+	if r.Value.String() != "live" || r.ContentType != "text/plain" || r.Version != "v1" ||
+		r.TTL <= 0 || r.TTL > 20*time.Millisecond || r.Metadata["Owner"] != "test" {
+		t.Fatalf("response metadata failed (got: %+v)", r)
+	}
+}
+
+func TestMetaEncoding(t *testing.T) {
+	m := &valueMeta{
+		expires:     time.Unix(0, time.Now().UnixNano()),
+		contentType: "application/json",
+		version:     "v1",
+		metadata:    map[string]string{"a": "1", "b": "2"},
+	}
+	b := appendMeta(nil, m)
+	if got := parseMeta(b); got == nil || !got.expires.Equal(m.expires) || got.contentType != m.contentType ||
+		got.version != m.version || !reflect.DeepEqual(got.metadata, m.metadata) {
+		t.Fatalf("meta encoding failed (expected: %+v, got: %+v)", m, got)
+	}
+	for i := range b {
+		if parseMeta(b[:i]) != nil {
+			t.Fatalf("meta parsing accepted a truncated encoding of %d bytes", i)
+		}
+	}
+}
+
+func TestMetaStores(t *testing.T) {
+	// The arena store and snapshots keep the metadata of the values.
+	g := NewGroup("meta_stores", 1<<10, resultGetter(map[string]int{}), WithStore(Arena))
+	defer g.Close()
+	g.Get("key")
+	if v, ok := g.mainCache.get("key"); !ok || v.result().ContentType != "text/plain" {
+		t.Fatalf("arena store lost the metadata")
+	}
+
+	var buf bytes.Buffer
+	if err := g.Snapshot(&buf); err != nil {
+		t.Fatalf("group snapshot failed: %v", err)
+	}
+	restored := NewGroup("meta_stores_restored", 1<<10, resultGetter(map[string]int{}))
+	defer restored.Close()
+	if err := restored.Restore(&buf); err != nil {
+		t.Fatalf("group restore failed: %v", err)
+	}
+	if v, ok := restored.mainCache.get("key"); !ok || v.result().Metadata["Owner"] != "test" {
+		t.Fatalf("snapshot lost the metadata")
+	}
+}
+
+// sidePeers owns the keys starting with "remote", and records the values pushed to it.
+type sidePeers struct {
+	pushed chan *pb.SetRequest
+}
+
+func (p sidePeers) PickPeer(key string) (Peer, bool) {
+	return p, strings.HasPrefix(key, "remote")
+}
+
+func (p sidePeers) Get(in *pb.Request, out *pb.Response) error {
+	return errors.New("not found")
+}
+
+func (p sidePeers) Set(in *pb.SetRequest) error {
+	p.pushed <- in
+	return nil
+}
+
+func TestSideLoad(t *testing.T) {
+	loads := map[string]int{}
+	getter := ResultGetterFunc(
+		func(key string) (LoadResult, error) {
+			loads[key]++
+			return LoadResult{Value: StringView(key), Extra: map[string]LoadResult{
+				key + ":1":           {Value: StringView("one"), ContentType: "text/plain"},
+				key + ":2":           {Value: StringView("two"), NoCache: true},
+				"remote" + key:       {Value: StringView("remote"), TTL: time.Minute},
+				"remote" + key + ":": {Value: StringView("remote")},
+			}}, nil
+		})
+	g := NewGroup("side_load", 1<<10, getter, WithPeerPush())
+	defer g.Close()
+	peers := sidePeers{pushed: make(chan *pb.SetRequest, 2)}
+	g.RegisterPeers(peers)
+
+	g.Get("row")
+	if r, err := g.GetResult("row:1"); err != nil || r.Value.String() != "one" || r.ContentType != "text/plain" || loads["row:1"] != 0 {
+		t.Fatalf("group failed to side-load row:1 (got: %+v, loads: %v)", r, loads["row:1"])
+	}
+	if _, ok := g.lookupCache("row:2"); ok {
+		t.Fatalf("group side-loaded a NoCache value")
+	}
+	if _, ok := g.lookupCache("remoterow"); ok {
+		t.Fatalf("group side-loaded a key owned by a peer")
+	}
+	pushed := map[string]*pb.SetRequest{}
+	for range 2 {
+		select {
+		case req := <-peers.pushed:
+			pushed[req.Key] = req
+		case <-time.After(time.Second):
+			t.Fatalf("group failed to push the keys to their owner (pushed: %v)", pushed)
+		}
+	}
+	if req := pushed["remoterow"]; req == nil || req.Group != "side_load" || string(req.Value) != "remote" || req.TtlMs != 60000 {
+		t.Fatalf("group pushed a wrong value to the peer (got: %v)", req)
+	}
+	if err := g.setFromPeer(&pb.SetRequest{Key: "remotekey", Value: []byte("value")}); err == nil {
+		t.Fatalf("group accepted a key owned by another peer")
+	}
+
+	private := NewGroup("side_load_private", 1<<10, getter)
+	defer private.Close()
+	private.RegisterPeers(peers)
+	private.Get("row")
+	select {
+	case req := <-peers.pushed:
+		t.Fatalf("group pushed a value without WithPeerPush (got: %v)", req)
+	case <-time.After(20 * time.Millisecond):
+	}
+	if err := private.setFromPeer(&pb.SetRequest{Key: "key", Value: []byte("value")}); err == nil {
+		t.Fatalf("group accepted a value without WithPeerPush")
+	}
+}
+
+// blockingPeers owns the keys starting with "remote", and blocks the values pushed to it until released.
+type blockingPeers struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (p blockingPeers) PickPeer(key string) (Peer, bool) {
+	return p, strings.HasPrefix(key, "remote")
+}
+
+func (p blockingPeers) Get(in *pb.Request, out *pb.Response) error {
+	return errors.New("not found")
+}
+
+func (p blockingPeers) Set(in *pb.SetRequest) error {
+	p.started <- struct{}{}
+	<-p.release
+	return nil
+}
+
+func TestSideLoadClose(t *testing.T) {
+	g := NewGroup("side_load_close", 1<<10, ResultGetterFunc(
+		func(key string) (LoadResult, error) {
+			return LoadResult{Value: StringView(key), Extra: map[string]LoadResult{
+				"remote" + key: {Value: StringView("remote")},
+			}}, nil
+		}), WithPeerPush())
+	peers := blockingPeers{started: make(chan struct{}), release: make(chan struct{})}
+	g.RegisterPeers(peers)
+	g.Get("row")
+	<-peers.started
+
+	// Close waits for the push in flight.
+	closed := make(chan struct{})
+	go func() {
+		g.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+		t.Fatalf("group closed before its push completed")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(peers.release)
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatalf("group failed to close after its push completed")
+	}
+}
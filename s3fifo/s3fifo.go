@@ -0,0 +1,245 @@
+package s3fifo
+
+import (
+	"container/list"
+	"iter"
+	"sync/atomic"
+)
+
+const (
+	smallRatio = 0.1 // the part of the capacity for the small FIFO
+	maxFreq    = 3   // the access frequency saturates like a 2-bit counter
+)
+
+// The queues an entry can be in.
+const (
+	small = iota // resident entries not yet accessed again, in FIFO order
+	main         // resident entries accessed again, in FIFO order with reinsertion
+	ghost        // ghosts of entries evicted from small, in FIFO order
+)
+
+// An S3-FIFO cache with byte capacity.
+//
+// New entries go to a small FIFO, and only the entries accessed while there move to the main FIFO,
+// so that one-hit wonders are evicted quickly. Entries evicted from the small FIFO are remembered
+// as ghosts, and an entry set again while its ghost is there goes directly to the main FIFO.
+// The main FIFO reinserts the entries accessed since they were last passed by the eviction.
+//
+// A hit only increments the frequency of the entry atomically and does not reorder the entries,
+// so it is safe to call Get concurrently with other calls of Get, but not with the other methods.
+type Cache struct {
+	capacity int64                         // the maximum size of the cache; capacity <= 0 means no limit
+	queues   [3]queue                      // small, main and ghost
+	cache    map[string]*list.Element      // the key to element mapping, including ghosts
+	onEvict  func(key string, value Value) // (optional) callback when an entry is evicted
+}
+
+// A queue is a FIFO list of entries with their total size.
+type queue struct {
+	ll   *list.List
+	size int64
+}
+
+// The element in the linked lists. The KV pair of the cache, or the key and size of a ghost.
+type entry struct {
+	key   string
+	value Value // nil for ghosts
+	size  int64
+	queue int
+	freq  atomic.Int32 // the number of accesses, up to maxFreq
+}
+
+// A Value in the cache implements the Len method to return its size in bytes.
+type Value interface {
+	Len() int // the size in bytes
+}
+
+// The constructor of Cache.
+func New(capacity int64, onEvict func(string, Value)) *Cache {
+	c := &Cache{
+		capacity: capacity,
+		cache:    make(map[string]*list.Element),
+		onEvict:  onEvict,
+	}
+	for i := range c.queues {
+		c.queues[i].ll = list.New()
+	}
+	return c
+}
+
+// Get gets the value from the cache by key.
+func (c *Cache) Get(key string) (Value, bool) {
+	ele, ok := c.cache[key]
+	if !ok {
+		return nil, false
+	}
+	kv := ele.Value.(*entry)
+	if kv.queue == ghost {
+		return nil, false
+	}
+	kv.access()
+	return kv.value, true
+}
+
+// Peek gets the value of key from the cache without updating its frequency.
+func (c *Cache) Peek(key string) (Value, bool) {
+	ele, ok := c.cache[key]
+	if !ok || ele.Value.(*entry).queue == ghost {
+		return nil, false
+	}
+	return ele.Value.(*entry).value, true
+}
+
+// Set sets a value with a key in the cache.
+//
+// An entry larger than the capacity is rejected rather than evicting the whole cache,
+// and the previous value of key, if any, is removed.
+func (c *Cache) Set(key string, value Value) {
+	size := int64(len(key)) + int64(value.Len())
+	if c.capacity > 0 && size > c.capacity {
+		c.Remove(key)
+		return
+	}
+	if ele, ok := c.cache[key]; ok {
+		kv := ele.Value.(*entry)
+		if kv.queue == ghost {
+			// Evicted from small too early: insert to main.
+			c.unlink(ele)
+			kv.value, kv.size = value, size
+			kv.freq.Store(0)
+			c.cache[key] = c.push(kv, main)
+		} else {
+			c.queues[kv.queue].size += size - kv.size
+			kv.value, kv.size = value, size
+			kv.access()
+		}
+	} else {
+		c.cache[key] = c.push(&entry{key: key, value: value, size: size}, small)
+	}
+	c.evict()
+}
+
+// Remove removes the entry of key from the cache.
+// The onEvict callback is called for the removed entry.
+func (c *Cache) Remove(key string) {
+	ele, ok := c.cache[key]
+	if !ok || ele.Value.(*entry).queue == ghost {
+		return
+	}
+	kv := c.unlink(ele)
+	delete(c.cache, key)
+	if c.onEvict != nil {
+		c.onEvict(kv.key, kv.value)
+	}
+}
+
+// Len returns the number of cache entries.
+func (c *Cache) Len() int {
+	return c.queues[small].ll.Len() + c.queues[main].ll.Len()
+}
+
+// Size returns the size of the cache entries in bytes.
+func (c *Cache) Size() int64 {
+	return c.queues[small].size + c.queues[main].size
+}
+
+// Resize changes the capacity of the cache, evicting entries if it shrinks.
+func (c *Cache) Resize(capacity int64) {
+	c.capacity = capacity
+	c.evict()
+}
+
+// Backward returns an iterator over the cache entries from the oldest to the newest
+// of the small FIFO and then of the main FIFO.
+// Accessed entries are moved or reinserted, so this is only the eviction order if none of them is accessed.
+func (c *Cache) Backward() iter.Seq2[string, Value] {
+	return func(yield func(string, Value) bool) {
+		for _, q := range []int{small, main} {
+			for ele := c.queues[q].ll.Back(); ele != nil; ele = ele.Prev() {
+				kv := ele.Value.(*entry)
+				if !yield(kv.key, kv.value) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// evict evicts entries until the cache fits its capacity.
+// The small FIFO is evicted from while it is over its share, and the main FIFO otherwise.
+func (c *Cache) evict() {
+	if c.capacity <= 0 {
+		return
+	}
+	smallCapacity := int64(float64(c.capacity) * smallRatio)
+	for c.Size() > c.capacity {
+		if c.queues[small].size > smallCapacity || c.queues[main].ll.Len() == 0 {
+			c.evictSmall()
+		} else {
+			c.evictMain()
+		}
+	}
+	// The ghosts are bounded by the size of the main FIFO.
+	for c.queues[ghost].size > c.capacity-smallCapacity {
+		kv := c.unlink(c.queues[ghost].ll.Back())
+		delete(c.cache, kv.key)
+	}
+}
+
+// evictSmall moves the oldest entry of the small FIFO to the main FIFO if it was accessed,
+// and evicts it to the ghost FIFO otherwise.
+func (c *Cache) evictSmall() {
+	kv := c.unlink(c.queues[small].ll.Back())
+	if kv.freq.Load() > 0 {
+		kv.freq.Store(0)
+		c.cache[kv.key] = c.push(kv, main)
+		return
+	}
+	value := kv.value
+	kv.value = nil
+	c.cache[kv.key] = c.push(kv, ghost)
+	if c.onEvict != nil {
+		c.onEvict(kv.key, value)
+	}
+}
+
+// evictMain reinserts the oldest entry of the main FIFO with a decremented frequency if it was accessed,
+// and evicts it otherwise.
+func (c *Cache) evictMain() {
+	ele := c.queues[main].ll.Back()
+	kv := ele.Value.(*entry)
+	if freq := kv.freq.Load(); freq > 0 {
+		kv.freq.Store(freq - 1)
+		c.queues[main].ll.MoveToFront(ele)
+		return
+	}
+	c.unlink(ele)
+	delete(c.cache, kv.key)
+	if c.onEvict != nil {
+		c.onEvict(kv.key, kv.value)
+	}
+}
+
+// access increments the frequency of the entry up to maxFreq.
+func (kv *entry) access() {
+	for freq := kv.freq.Load(); freq < maxFreq; freq = kv.freq.Load() {
+		if kv.freq.CompareAndSwap(freq, freq+1) {
+			return
+		}
+	}
+}
+
+// push pushes an entry to the front of a queue.
+func (c *Cache) push(kv *entry, q int) *list.Element {
+	kv.queue = q
+	c.queues[q].size += kv.size
+	return c.queues[q].ll.PushFront(kv)
+}
+
+// unlink removes an element from its queue.
+func (c *Cache) unlink(ele *list.Element) *entry {
+	kv := ele.Value.(*entry)
+	c.queues[kv.queue].ll.Remove(ele)
+	c.queues[kv.queue].size -= kv.size
+	return kv
+}
@@ -0,0 +1,105 @@
+package s3fifo
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+type value string
+
+func (v value) Len() int {
+	return len(v)
+}
+
+func TestGet(t *testing.T) {
+	c := New(int64(0), nil)
+	c.Set("k1", value("v1"))
+	if v, ok := c.Get("k1"); !ok || string(v.(value)) != "v1" {
+		t.Fatalf("cache hit k1=v1 failed")
+	}
+	if _, ok := c.Get("k2"); ok {
+		t.Fatalf("cache miss k2 failed")
+	}
+	c.Set("k1", value("value1"))
+	if c.Size() != int64(len("k1"+"value1")) || c.Len() != 1 {
+		t.Fatalf("cache set failed (expected: %v, got: %v)", len("k1"+"value1"), c.Size())
+	}
+}
+
+func TestEviction(t *testing.T) {
+	keys := []string{}
+	c := New(int64(40), func(key string, value Value) {
+		keys = append(keys, key)
+	})
+	for i := range 10 {
+		c.Set(fmt.Sprintf("k%02d", i), value("v"))
+	}
+	// k00 is accessed while in the small FIFO, so it moves to main instead of being evicted.
+	c.Get("k00")
+	c.Set("k10", value("v"))
+	c.Set("k11", value("v"))
+	if expected := []string{"k01", "k02"}; !reflect.DeepEqual(expected, keys) {
+		t.Fatalf("cache eviction failed (expected: %v, got: %v)", expected, keys)
+	}
+	if c.queues[main].ll.Len() != 1 {
+		t.Fatalf("cache failed to move k00 to main")
+	}
+	// k01 is a ghost, so setting it again inserts it to main.
+	if _, ok := c.Get("k01"); ok {
+		t.Fatalf("cache hit a ghost")
+	}
+	c.Set("k01", value("v"))
+	if c.queues[main].ll.Len() != 2 || c.Size() > 40 {
+		t.Fatalf("cache failed to insert k01 to main")
+	}
+	// A scan of one-off keys does not evict the entries of main.
+	for i := range 100 {
+		c.Set(fmt.Sprintf("s%02d", i), value("v"))
+	}
+	for _, key := range []string{"k00", "k01"} {
+		if _, ok := c.Get(key); !ok {
+			t.Fatalf("cache failed to resist the scan (evicted: %v)", key)
+		}
+	}
+}
+
+func TestConcurrentGet(t *testing.T) {
+	c := New(int64(0), nil)
+	for i := range 100 {
+		c.Set(fmt.Sprintf("k%d", i), value("v"))
+	}
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 100 {
+				if _, ok := c.Get(fmt.Sprintf("k%d", i)); !ok {
+					t.Errorf("cache hit k%d failed", i)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if freq := c.cache["k0"].Value.(*entry).freq.Load(); freq != maxFreq {
+		t.Fatalf("cache access count failed (expected: %v, got: %v)", maxFreq, freq)
+	}
+}
+
+func TestRemove(t *testing.T) {
+	keys := []string{}
+	c := New(int64(0), func(key string, value Value) {
+		keys = append(keys, key)
+	})
+	c.Set("k1", value("v1"))
+	c.Remove("k1")
+	c.Remove("k2")
+	if _, ok := c.Get("k1"); ok || c.Len() != 0 || c.Size() != 0 {
+		t.Fatalf("cache remove k1 failed")
+	}
+	if expected := []string{"k1"}; !reflect.DeepEqual(expected, keys) {
+		t.Fatalf("cache remove callback failed (expected: %v, got: %v)", expected, keys)
+	}
+}
@@ -0,0 +1,301 @@
+package gocache
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// The snapshot format is:
+//
+//	magic   [4]byte  "GCSN"
+//	version uint16   big endian
+//	entries          the pinned ones, then the others from the least to the most recently used, each being
+//	                 0x02 if it is pinned (since version 3) or 0x01, uvarint key length, key, uvarint value length, value,
+//	                 uvarint meta length, meta (the metadata of the value, empty if it has none)
+//	                 or in version 1, varint expiry in Unix nanoseconds (0 means no expiry)
+//	end     byte     0x00
+//	count   uvarint  the number of entries
+//	crc     uint32   big endian CRC-32 (IEEE) of all the preceding bytes
+const (
+	snapshotMagic   = "GCSN"
+	snapshotVersion = 3
+
+	snapshotEntryTag  = 0x01
+	snapshotPinnedTag = 0x02
+	snapshotEndTag    = 0x00
+
+	maxSnapshotFieldLen = 1 << 30 // guards against huge allocations on corrupted input
+)
+
+// ErrBadSnapshot is returned by Restore when the snapshot is malformed or corrupted.
+var ErrBadSnapshot = errors.New("gocache: bad snapshot")
+
+// WithSnapshot makes the group restore its cache from the snapshot file at path when it is created.
+// If interval is positive, a snapshot is also written to path periodically.
+// A final snapshot is written when the group is closed.
+func WithSnapshot(path string, interval time.Duration) GroupOption {
+	return func(g *Group) {
+		g.snapshotPath = path
+		g.snapshotInterval = interval
+	}
+}
+
+// Snapshot writes the contents of the group's cache to w.
+// The entries are written uncompressed and in recency order so that Restore preserves it.
+// The pinned entries are written too, and restored pinned.
+func (g *Group) Snapshot(w io.Writer) error {
+	sw := &snapshotWriter{w: bufio.NewWriter(w), crc: crc32.NewIEEE()}
+	sw.write([]byte(snapshotMagic))
+	sw.write(binary.BigEndian.AppendUint16(nil, snapshotVersion))
+	pinned := g.pinnedItems()
+	items := append(pinned, g.mainCache.items()...)
+	for i, it := range items {
+		value, err := it.value.decompress()
+		if err != nil {
+			return fmt.Errorf("decompressing key=%s: %v", it.key, err)
+		}
+		if i < len(pinned) {
+			sw.writeByte(snapshotPinnedTag)
+		} else {
+			sw.writeByte(snapshotEntryTag)
+		}
+		sw.writeBytes([]byte(it.key))
+		sw.writeBytes(value.data())
+		var meta []byte
+		if value.meta != nil {
+			meta = appendMeta(nil, value.meta)
+		}
+		sw.writeBytes(meta)
+	}
+	sw.writeByte(snapshotEndTag)
+	sw.write(binary.AppendUvarint(nil, uint64(len(items))))
+	sw.write(binary.BigEndian.AppendUint32(nil, sw.crc.Sum32()))
+	if sw.err != nil {
+		return sw.err
+	}
+	return sw.w.Flush()
+}
+
+// Restore reads a snapshot written by Snapshot from r and populates the group's cache with it.
+// Nothing is populated if the snapshot turns out to be malformed.
+func (g *Group) Restore(r io.Reader) error {
+	sr := &snapshotReader{r: bufio.NewReader(r), crc: crc32.NewIEEE()}
+	header := make([]byte, len(snapshotMagic)+2)
+	if _, err := io.ReadFull(sr, header); err != nil {
+		return fmt.Errorf("%w: reading header: %v", ErrBadSnapshot, err)
+	}
+	if string(header[:len(snapshotMagic)]) != snapshotMagic {
+		return fmt.Errorf("%w: unknown magic %q", ErrBadSnapshot, header[:len(snapshotMagic)])
+	}
+	version := binary.BigEndian.Uint16(header[len(snapshotMagic):])
+	if version < 1 || version > snapshotVersion {
+		return fmt.Errorf("%w: unsupported version %d", ErrBadSnapshot, version)
+	}
+
+	var items, pinned []item
+	var n uint64 // the number of entries read, including the expired ones
+	for {
+		tag, err := sr.ReadByte()
+		if err != nil {
+			return fmt.Errorf("%w: reading entry: %v", ErrBadSnapshot, err)
+		}
+		if tag == snapshotEndTag {
+			break
+		}
+		if tag != snapshotEntryTag && (tag != snapshotPinnedTag || version < 3) {
+			return fmt.Errorf("%w: unknown entry tag %#x", ErrBadSnapshot, tag)
+		}
+		key, err := sr.readBytes()
+		if err != nil {
+			return fmt.Errorf("%w: reading key: %v", ErrBadSnapshot, err)
+		}
+		value, err := sr.readBytes()
+		if err != nil {
+			return fmt.Errorf("%w: reading value: %v", ErrBadSnapshot, err)
+		}
+		n++
+		view := ByteView{bytes: value}
+		if version == 1 {
+			if expires, err := binary.ReadVarint(sr); err != nil {
+				return fmt.Errorf("%w: reading expiry: %v", ErrBadSnapshot, err)
+			} else if expires != 0 {
+				view.meta = &valueMeta{expires: time.Unix(0, expires)}
+			}
+		} else {
+			meta, err := sr.readBytes()
+			if err != nil {
+				return fmt.Errorf("%w: reading meta: %v", ErrBadSnapshot, err)
+			}
+			if len(meta) > 0 {
+				if view.meta = parseMeta(meta); view.meta == nil {
+					return fmt.Errorf("%w: malformed meta of key=%s", ErrBadSnapshot, key)
+				}
+			}
+		}
+		if view.expired() {
+			continue
+		}
+		it := item{key: string(key), value: g.compress(view)}
+		if tag == snapshotPinnedTag {
+			pinned = append(pinned, it)
+		} else {
+			items = append(items, it)
+		}
+	}
+
+	count, err := binary.ReadUvarint(sr)
+	if err != nil {
+		return fmt.Errorf("%w: reading entry count: %v", ErrBadSnapshot, err)
+	}
+	if count != n {
+		return fmt.Errorf("%w: expected %d entries, got %d", ErrBadSnapshot, count, n)
+	}
+	sum := sr.crc.Sum32()
+	crc := make([]byte, 4)
+	if _, err := io.ReadFull(sr.r, crc); err != nil {
+		return fmt.Errorf("%w: reading checksum: %v", ErrBadSnapshot, err)
+	}
+	if binary.BigEndian.Uint32(crc) != sum {
+		return fmt.Errorf("%w: checksum mismatch", ErrBadSnapshot)
+	}
+
+	for _, it := range pinned {
+		g.populatePinned(it.key, it.value)
+	}
+	for _, it := range items {
+		g.populateCache(it.key, it.value)
+	}
+	return nil
+}
+
+// SnapshotFile writes a snapshot of the group's cache to the file at path.
+// The file is replaced atomically so that a crash never leaves a partial snapshot behind.
+func (g *Group) SnapshotFile(path string) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if err := g.Snapshot(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// RestoreFile populates the group's cache with the snapshot file at path.
+func (g *Group) RestoreFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return g.Restore(f)
+}
+
+// restoreSnapshot restores the configured snapshot file if it exists.
+func (g *Group) restoreSnapshot() {
+	err := g.RestoreFile(g.snapshotPath)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		log.Printf("[gocache] failed to restore group %s from %s: %v", g.name, g.snapshotPath, err)
+	default:
+		log.Printf("[gocache] restored group %s from %s", g.name, g.snapshotPath)
+	}
+}
+
+// snapshotLoop writes the configured snapshot file every interval until the group is closed.
+func (g *Group) snapshotLoop() {
+	defer g.wg.Done()
+	ticker := time.NewTicker(g.snapshotInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := g.SnapshotFile(g.snapshotPath); err != nil {
+				log.Printf("[gocache] failed to snapshot group %s: %v", g.name, err)
+			}
+		case <-g.done:
+			return
+		}
+	}
+}
+
+// A snapshotWriter writes to w and checksums everything written.
+// The first error is kept and turns further writes into no-ops.
+type snapshotWriter struct {
+	w   *bufio.Writer
+	crc hash.Hash32
+	err error
+}
+
+func (sw *snapshotWriter) write(p []byte) {
+	if sw.err != nil {
+		return
+	}
+	_, sw.err = sw.w.Write(p)
+	sw.crc.Write(p)
+}
+
+func (sw *snapshotWriter) writeByte(b byte) {
+	sw.write([]byte{b})
+}
+
+// writeBytes writes p prefixed with its length.
+func (sw *snapshotWriter) writeBytes(p []byte) {
+	sw.write(binary.AppendUvarint(nil, uint64(len(p))))
+	sw.write(p)
+}
+
+// A snapshotReader reads from r and checksums everything read.
+type snapshotReader struct {
+	r   *bufio.Reader
+	crc hash.Hash32
+}
+
+func (sr *snapshotReader) Read(p []byte) (int, error) {
+	n, err := sr.r.Read(p)
+	sr.crc.Write(p[:n])
+	return n, err
+}
+
+func (sr *snapshotReader) ReadByte() (byte, error) {
+	b, err := sr.r.ReadByte()
+	if err == nil {
+		sr.crc.Write([]byte{b})
+	}
+	return b, err
+}
+
+// readBytes reads a length-prefixed byte slice.
+func (sr *snapshotReader) readBytes() ([]byte, error) {
+	n, err := binary.ReadUvarint(sr)
+	if err != nil {
+		return nil, err
+	}
+	if n > maxSnapshotFieldLen {
+		return nil, fmt.Errorf("field length %d is too large", n)
+	}
+	p := make([]byte, n)
+	if _, err := io.ReadFull(sr, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
@@ -0,0 +1,108 @@
+package gocache
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSnapshotRestore(t *testing.T) {
+	getter := GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key + "_value"), nil
+	})
+	src := NewGroup("snapshot_src", 0, getter)
+	for _, k := range []string{"k1", "k2", "k3"} {
+		src.Get(k)
+	}
+	src.Get("k1")
+
+	buf := new(bytes.Buffer)
+	if err := src.Snapshot(buf); err != nil {
+		t.Fatalf("snapshot failed: %v", err)
+	}
+	dst := NewGroup("snapshot_dst", 0, GetterFunc(func(key string) ([]byte, error) {
+		t.Fatalf("restored group loaded key=%s", key)
+		return nil, nil
+	}))
+	if err := dst.Restore(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatalf("restore failed: %v", err)
+	}
+
+	expected := []string{"k2", "k3", "k1"}
+	keys := []string{}
+	for _, it := range dst.mainCache.items() {
+		keys = append(keys, it.key)
+		if it.value.String() != it.key+"_value" {
+			t.Fatalf("restore failed with key=%s (expected: %s_value, got: %s)", it.key, it.key, it.value)
+		}
+	}
+	if !reflect.DeepEqual(expected, keys) {
+		t.Fatalf("restore failed to preserve recency (expected: %v, got: %v)", expected, keys)
+	}
+}
+
+func TestSnapshotPinned(t *testing.T) {
+	getter := GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	})
+	src := NewGroup("snapshot_pinned_src", 0, getter)
+	src.Get("k1")
+	src.Pin("p1")
+
+	buf := new(bytes.Buffer)
+	if err := src.Snapshot(buf); err != nil {
+		t.Fatalf("snapshot failed: %v", err)
+	}
+	dst := NewGroup("snapshot_pinned_dst", 0, getter)
+	if err := dst.Restore(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatalf("restore failed: %v", err)
+	}
+	if v, ok := dst.getPinned("p1"); !ok || v.String() != "p1" {
+		t.Fatalf("restore failed to pin key=p1")
+	}
+	if _, ok := dst.mainCache.get("k1"); !ok || dst.mainCache.len() != 1 {
+		t.Fatalf("restore failed with key=k1 (len: %d)", dst.mainCache.len())
+	}
+}
+
+func TestRestoreCorrupted(t *testing.T) {
+	src := NewGroup("snapshot_corrupted", 0, GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	}))
+	src.Get("key")
+	buf := new(bytes.Buffer)
+	src.Snapshot(buf)
+
+	b := buf.Bytes()
+	b[len(snapshotMagic)+5] ^= 0xff // flip a byte of the first key
+	dst := NewGroup("snapshot_corrupted_dst", 0, src.getter)
+	if err := dst.Restore(bytes.NewReader(b)); !errors.Is(err, ErrBadSnapshot) {
+		t.Fatalf("restore of corrupted snapshot failed (expected: %v, got: %v)", ErrBadSnapshot, err)
+	}
+	if items := dst.mainCache.items(); len(items) != 0 {
+		t.Fatalf("restore of corrupted snapshot populated the cache: %v", items)
+	}
+}
+
+func TestWithSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot")
+	loads := 0
+	getter := GetterFunc(func(key string) ([]byte, error) {
+		loads++
+		return []byte(key), nil
+	})
+	g := NewGroup("snapshot_file", 0, getter, WithSnapshot(path, time.Hour))
+	g.Get("key")
+	if err := g.Close(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+
+	g = NewGroup("snapshot_file", 0, getter, WithSnapshot(path, time.Hour))
+	defer g.Close()
+	if v, err := g.Get("key"); err != nil || v.String() != "key" || loads != 1 {
+		t.Fatalf("restore from snapshot file failed (loads: %d)", loads)
+	}
+}
@@ -0,0 +1,60 @@
+package gocache
+
+import "sync/atomic"
+
+// Stats are the statistics of a group since its creation.
+type Stats struct {
+	Gets       int64 // the calls of Get
+	Hits       int64 // the Gets served from memory
+	Loads      int64 // the loads of the values missing from memory, after deduplication of concurrent Gets
+	DiskHits   int64 // the loads served by the disk tier
+	PeerLoads  int64 // the loads served by peers
+	LocalLoads int64 // the loads served by the getter
+	Batches    int64 // the batches of local loads, see WithBatching
+	Rejected   int64 // the loaded values not cached because of their size
+
+	Pinned     int64 // the number of pinned entries
+	PinnedSize int64 // the size of the pinned entries, len(key) + len(value), in bytes
+
+	Memory      int64   // the estimated memory used by the entries of the main cache, including their overhead
+	MemoryRatio float64 // Memory / the capacity of the main cache; 0 if it has no capacity
+
+	MissRatioCurve []MissRatioPoint // the estimated miss ratios at other capacities, see WithMissRatioCurve
+}
+
+// groupStats are the counters behind Stats.
+type groupStats struct {
+	gets, hits, loads, diskHits, peerLoads, localLoads, batches, rejected atomic.Int64
+}
+
+// Stats returns the statistics of the group.
+func (g *Group) Stats() Stats {
+	pinned, pinnedSize := g.pinnedStats()
+	memory := g.mainCache.memory()
+	var curve []MissRatioPoint
+	if g.mrc != nil {
+		curve = g.mrc.curve()
+	}
+	var ratio float64
+	if capacity := g.mainCache.getCapacity(); capacity > 0 {
+		ratio = float64(memory) / float64(capacity)
+	}
+	return Stats{
+		Gets:       g.stats.gets.Load(),
+		Hits:       g.stats.hits.Load(),
+		Loads:      g.stats.loads.Load(),
+		DiskHits:   g.stats.diskHits.Load(),
+		PeerLoads:  g.stats.peerLoads.Load(),
+		LocalLoads: g.stats.localLoads.Load(),
+		Batches:    g.stats.batches.Load(),
+		Rejected:   g.stats.rejected.Load(),
+
+		Pinned:     pinned,
+		PinnedSize: pinnedSize,
+
+		Memory:      memory,
+		MemoryRatio: ratio,
+
+		MissRatioCurve: curve,
+	}
+}
@@ -0,0 +1,101 @@
+package gocache
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	g := NewGroup("stats", 0, GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte(key), nil
+		}))
+	defer g.Close()
+
+	g.Get("k1")
+	g.Get("k1")
+	g.Get("k2")
+	memory := 2 * (int64(len("k1"+"k1")) + entryOverhead)
+	expected := Stats{Gets: 3, Hits: 1, Loads: 2, LocalLoads: 2, Memory: memory}
+	if stats := g.Stats(); !reflect.DeepEqual(expected, stats) {
+		t.Fatalf("group stats failed (expected: %+v, got: %+v)", expected, stats)
+	}
+}
+
+func TestOverheadAccounting(t *testing.T) {
+	// Every entry is 4 + 8 bytes, e.g. key=k001 and value=value001, plus the overhead.
+	capacity := 10 * (12 + entryOverhead)
+	getter := GetterFunc(func(key string) ([]byte, error) {
+		return []byte("value" + key[1:]), nil
+	})
+	accounted := NewGroup("overhead_accounted", capacity, getter, WithOverheadAccounting())
+	unaccounted := NewGroup("overhead_unaccounted", capacity, getter)
+	defer accounted.Close()
+	defer unaccounted.Close()
+
+	for i := range 100 {
+		key := fmt.Sprintf("k%03d", i)
+		accounted.Get(key)
+		unaccounted.Get(key)
+	}
+	if n := accounted.mainCache.len(); n != 10 {
+		t.Fatalf("overhead accounting failed (expected: %v entries, got: %v)", 10, n)
+	}
+	if ratio := accounted.Stats().MemoryRatio; ratio > 1 {
+		t.Fatalf("overhead accounting exceeded the capacity (ratio: %v)", ratio)
+	}
+	if ratio := unaccounted.Stats().MemoryRatio; ratio <= 1 {
+		t.Fatalf("memory ratio failed to report the overhead (ratio: %v)", ratio)
+	}
+}
+
+func TestOverheadEviction(t *testing.T) {
+	// Every entry is 4 + 8 bytes, e.g. key=k001 and value=value001, plus the overhead.
+	evicted := []string{}
+	g := NewGroup("overhead_eviction", 10*(12+entryOverhead), GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte("value" + key[1:]), nil
+		}), WithOverheadAccounting(), WithEvictHook(
+		func(key string, value ByteView, reason EvictReason) {
+			evicted = append(evicted, key)
+		}))
+	defer g.Close()
+
+	for i := range 10 {
+		g.Get(fmt.Sprintf("k%03d", i))
+	}
+	if n := g.mainCache.len(); n != 10 || len(evicted) != 0 {
+		t.Fatalf("overhead accounting failed to fill the cache (len: %v, evicted: %v)", n, evicted)
+	}
+	g.SetCapacity(9 * (12 + entryOverhead))
+	if expected := []string{"k000"}; !reflect.DeepEqual(expected, evicted) || g.mainCache.len() != 9 {
+		t.Fatalf("overhead accounting evicted more than needed (expected: %v, got: %v)", expected, evicted)
+	}
+}
+
+// TestEntryOverhead validates the estimated memory of the main cache against the heap usage.
+func TestEntryOverhead(t *testing.T) {
+	const n = 100000
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key%08d", i)
+	}
+	c := &cache{}
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	for _, k := range keys {
+		c.set(k, ByteView{bytes: make([]byte, 16)})
+	}
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	heap := int64(after.HeapAlloc) - int64(before.HeapAlloc)
+	// The keys were allocated before.
+	estimate := c.memory() - int64(n*len(keys[0]))
+	if ratio := float64(heap) / float64(estimate); ratio < 0.8 || ratio > 1.25 {
+		t.Fatalf("entry overhead estimate failed (heap: %d, estimate: %d)", heap, estimate)
+	}
+	runtime.KeepAlive(c)
+}
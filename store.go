@@ -0,0 +1,428 @@
+package gocache
+
+import (
+	"iter"
+	"slices"
+
+	"github.com/thezbm/gocache/arc"
+	"github.com/thezbm/gocache/arena"
+	"github.com/thezbm/gocache/clock"
+	"github.com/thezbm/gocache/gdsf"
+	"github.com/thezbm/gocache/lru"
+	"github.com/thezbm/gocache/s3fifo"
+	"github.com/thezbm/gocache/tinylfu"
+	"github.com/thezbm/gocache/twoq"
+)
+
+// A Store holds the entries of a Group's main cache and decides which ones to evict.
+// The size of an entry is len(key) + value.Len(), and the store evicts entries to keep their total
+// size within its capacity, calling onEvict for each of them with EvictCapacity, as well as for removed
+// entries with EvictRemoved. A store may also report the values replaced by Set with EvictReplaced,
+// as every store of this package does.
+// A capacity <= 0 means no limit.
+//
+// A Store does not need to be safe for concurrent use.
+type Store interface {
+	Get(key string) (ByteView, bool)
+	Set(key string, value ByteView)
+	Remove(key string)
+	Len() int                              // the number of entries
+	Size() int64                           // the total size of the entries in bytes
+	Resize(capacity int64)                 // changes the capacity, evicting entries if it shrinks
+	Backward() iter.Seq2[string, ByteView] // iterates over the entries from the first to the last to be evicted
+}
+
+// A SharedStore is a Store whose GetShared can run concurrently with other calls of GetShared,
+// e.g. because hits only set a flag atomically instead of reordering the entries.
+// The main cache then serves its hits under a read lock.
+type SharedStore interface {
+	Store
+	GetShared(key string) (ByteView, bool)
+}
+
+// holds reports whether store holds key after a Set, as a store may reject an entry, e.g. larger than its capacity.
+// The stores that cannot tell without accessing the entry are assumed to hold it.
+func holds(store Store, key string) bool {
+	switch s := store.(type) {
+	case interface{ contains(key string) bool }:
+		return s.contains(key)
+	case *arenaStore:
+		return s.c.Contains(key)
+	}
+	return true
+}
+
+// An overheadStore is a Store whose memory used for each entry besides its key and value differs from
+// the one of the LRU store, see entryOverhead.
+type overheadStore interface {
+	Store
+	overhead() int64
+}
+
+// storeOverhead returns the estimated memory used by store for each entry in addition to its key and value.
+func storeOverhead(store Store) int64 {
+	if s, ok := store.(overheadStore); ok {
+		return s.overhead()
+	}
+	return entryOverhead
+}
+
+// An evictingStore is a Store that evicts its next entry on its own, as its capacity is not in the unit of Size.
+type evictingStore interface {
+	Store
+	evictNext() bool
+}
+
+// A NewStoreFunc creates a Store of capacity bytes.
+type NewStoreFunc func(capacity int64, onEvict func(key string, value ByteView, reason EvictReason)) Store
+
+// WithStore makes the group create its main cache with newStore, e.g. to pick an eviction policy.
+// Each shard of the main cache is a separate store. The default is LRU.
+func WithStore(newStore NewStoreFunc) GroupOption {
+	return func(g *Group) {
+		g.mainCache.newStore = newStore
+	}
+}
+
+// LRU creates a Store evicting the least recently used entries.
+func LRU(capacity int64, onEvict func(key string, value ByteView, reason EvictReason)) Store {
+	var evict func(string, ByteView, lru.EvictReason)
+	if onEvict != nil {
+		evict = func(key string, value ByteView, reason lru.EvictReason) {
+			onEvict(key, value, lruEvictReason(reason))
+		}
+	}
+	return &valueStore[ByteView]{c: lru.NewCache(capacity, entrySize, evict)}
+}
+
+// TinyLFU creates a Store with the W-TinyLFU policy, which resists scans of one-off keys
+// by only admitting entries accessed more often than the ones they would replace.
+func TinyLFU(capacity int64, onEvict func(key string, value ByteView, reason EvictReason)) Store {
+	s := &valueStore[tinylfu.Value]{}
+	s.c = tinylfu.New(capacity, evictValue(s, onEvict))
+	return s
+}
+
+// ARC creates a Store with the ARC policy, which adapts between recency and frequency
+// by remembering the keys of recently evicted entries.
+func ARC(capacity int64, onEvict func(key string, value ByteView, reason EvictReason)) Store {
+	s := &valueStore[arc.Value]{}
+	s.c = arc.New(capacity, evictValue(s, onEvict))
+	return s
+}
+
+// TwoQ creates a Store with the 2Q policy, which resists scans by only admitting to its main LRU
+// the entries set again shortly after being evicted from a FIFO of new entries.
+func TwoQ(capacity int64, onEvict func(key string, value ByteView, reason EvictReason)) Store {
+	s := &valueStore[twoq.Value]{}
+	s.c = twoq.New(capacity, evictValue(s, onEvict))
+	return s
+}
+
+// CLOCK creates a SharedStore with the CLOCK policy, which approximates LRU
+// by giving a second chance to the entries hit since they were inserted.
+func CLOCK(capacity int64, onEvict func(key string, value ByteView, reason EvictReason)) Store {
+	s := &sharedValueStore[clock.Value]{}
+	s.c = clock.New(capacity, evictValue(&s.valueStore, onEvict))
+	return s
+}
+
+// S3FIFO creates a SharedStore with the S3-FIFO policy, which quickly evicts the entries
+// not hit again after their insertion, and keeps the others in a FIFO with reinsertion.
+func S3FIFO(capacity int64, onEvict func(key string, value ByteView, reason EvictReason)) Store {
+	s := &sharedValueStore[s3fifo.Value]{}
+	s.c = s3fifo.New(capacity, evictValue(&s.valueStore, onEvict))
+	return s
+}
+
+// GDSF creates a Store with the GreedyDual-Size-Frequency policy, which evicts first the entries
+// of the lowest frequency * cost / size, where the cost of an entry is the latency of loading it from the getter,
+// so that the entries slow to load again are kept longer than the fast ones.
+// The costs are in seconds, and every entry is set with one, so that the cost of 1 of gdsf.Cache.Set is never mixed in.
+// The latency of a batched value is the one of its batch, without the wait for the batch to fill up, see WithBatching.
+func GDSF(capacity int64, onEvict func(key string, value ByteView, reason EvictReason)) Store {
+	s := &gdsfStore{}
+	s.cache = gdsf.New(capacity, evictValue(&s.valueStore, onEvict))
+	s.c = s.cache
+	return s
+}
+
+// A gdsfStore adapts a gdsf.Cache to a Store, setting the values with their load latency in seconds as cost.
+type gdsfStore struct {
+	valueStore[gdsf.Value]
+	cache *gdsf.Cache
+	costs float64 // the total cost of the values set with a load latency
+	n     int64   // the number of values set with a load latency
+}
+
+func (s *gdsfStore) Set(key string, value ByteView) {
+	cost := value.cost.Seconds()
+	if cost > 0 {
+		s.costs += cost
+		s.n++
+	} else if s.n > 0 {
+		// The values not loaded from the getter, e.g. promoted from disk or restored from a snapshot,
+		// get the average cost, so that they are neither favored nor evicted first.
+		cost = s.costs / float64(s.n)
+	}
+	s.replace(key, func() { s.cache.SetWithCost(key, value, cost) })
+}
+
+// entrySize returns the size of an entry in a Store.
+func entrySize(key string, value ByteView) int64 {
+	return int64(len(key)) + int64(value.Len())
+}
+
+// A valueCache is a cache of values implementing Len, like lru.Cache.
+type valueCache[V any] interface {
+	Get(key string) (V, bool)
+	Set(key string, value V)
+	Remove(key string)
+	Len() int
+	Size() int64
+	Resize(capacity int64)
+	Backward() iter.Seq2[string, V]
+}
+
+// A valueStore adapts a valueCache to a Store, as ByteView implements Len.
+// It reports the values replaced by Set for the caches that do not report them themselves, see evictValue.
+type valueStore[V any] struct {
+	c        valueCache[V]
+	removing bool // whether the entries evicted by c are removed by Remove
+
+	onEvict func(string, ByteView, EvictReason) // (optional) called for the values replaced by Set
+	setting string                              // the key being set
+	evicted bool                                // whether the key being set was evicted by c
+}
+
+// A peekCache is a valueCache able to get a value without accessing it.
+type peekCache[V any] interface {
+	Peek(key string) (V, bool)
+}
+
+// contains reports whether c holds key, or true if c cannot tell without accessing the entry.
+func (s *valueStore[V]) contains(key string) bool {
+	if c, ok := s.c.(peekCache[V]); ok {
+		_, ok := c.Peek(key)
+		return ok
+	}
+	return true
+}
+
+func (s *valueStore[V]) Get(key string) (ByteView, bool) {
+	if v, ok := s.c.Get(key); ok {
+		return any(v).(ByteView), true
+	}
+	return ByteView{}, false
+}
+
+func (s *valueStore[V]) Set(key string, value ByteView) {
+	s.replace(key, func() { s.c.Set(key, any(value).(V)) })
+}
+
+// replace runs set, which sets the value of key in c, and reports the value it replaces with EvictReplaced.
+// A value removed by set, e.g. as the new one is rejected, is reported by c instead.
+func (s *valueStore[V]) replace(key string, set func()) {
+	c, ok := s.c.(peekCache[V])
+	if s.onEvict == nil || !ok {
+		set()
+		return
+	}
+	old, replacing := c.Peek(key)
+	s.setting, s.evicted = key, false
+	set()
+	s.setting = ""
+	if replacing && !s.evicted {
+		s.onEvict(key, any(old).(ByteView), EvictReplaced)
+	}
+}
+
+func (s *valueStore[V]) Remove(key string) {
+	s.removing = true
+	s.c.Remove(key)
+	s.removing = false
+}
+
+func (s *valueStore[V]) Len() int {
+	return s.c.Len()
+}
+
+func (s *valueStore[V]) Size() int64 {
+	return s.c.Size()
+}
+
+func (s *valueStore[V]) Resize(capacity int64) {
+	s.c.Resize(capacity)
+}
+
+func (s *valueStore[V]) Backward() iter.Seq2[string, ByteView] {
+	return func(yield func(string, ByteView) bool) {
+		for k, v := range s.c.Backward() {
+			if !yield(k, any(v).(ByteView)) {
+				return
+			}
+		}
+	}
+}
+
+// A sharedValueStore adapts a valueCache whose Get can run concurrently with itself to a SharedStore.
+type sharedValueStore[V any] struct {
+	valueStore[V]
+}
+
+func (s *sharedValueStore[V]) GetShared(key string) (ByteView, bool) {
+	return s.Get(key)
+}
+
+// evictValue adapts an onEvict callback of ByteViews to one of the values of the valueCache of s,
+// which does not report the values it replaces, so that s reports them to onEvict.
+func evictValue[V any](s *valueStore[V], onEvict func(string, ByteView, EvictReason)) func(string, V) {
+	if onEvict == nil {
+		return nil
+	}
+	s.onEvict = onEvict
+	return func(key string, value V) {
+		if key == s.setting {
+			s.evicted = true
+		}
+		reason := EvictCapacity
+		if s.removing {
+			reason = EvictRemoved
+		}
+		onEvict(key, any(value).(ByteView), reason)
+	}
+}
+
+// Arena creates a SharedStore keeping the entries in a ring buffer of bytes indexed by key hashes,
+// which the garbage collector does not need to scan, so that its pauses do not grow with the number of entries.
+// It evicts the oldest entries, and its values are copied out of the buffer on every hit.
+// The capacity must not exceed 4 GiB per shard.
+func Arena(capacity int64, onEvict func(key string, value ByteView, reason EvictReason)) Store {
+	s := &arenaStore{onEvict: onEvict}
+	s.c = arena.New(capacity, func(key string, value, meta []byte) {
+		if onEvict == nil {
+			return
+		}
+		if key == s.setting {
+			s.evicted = true
+		}
+		reason := EvictCapacity
+		if s.removing {
+			reason = EvictRemoved
+		}
+		onEvict(key, s.view(value, meta), reason)
+	})
+	return s
+}
+
+// An arenaStore adapts an arena.Cache to a SharedStore.
+// The compressor of a value is stored in its meta as an index into the compressors of the store.
+type arenaStore struct {
+	c           *arena.Cache
+	compressors []Compressor // the compressors of the values, by their index + 1 in meta
+	removing    bool         // whether the entries evicted by c are removed by Remove
+
+	onEvict func(string, ByteView, EvictReason) // (optional) called for the values replaced by Set
+	setting string                              // the key being set
+	evicted bool                                // whether the key being set was evicted by c
+}
+
+func (s *arenaStore) Get(key string) (ByteView, bool) {
+	value, meta, ok := s.c.Get(key)
+	if !ok {
+		return ByteView{}, false
+	}
+	return s.view(value, meta), true
+}
+
+func (s *arenaStore) GetShared(key string) (ByteView, bool) {
+	return s.Get(key)
+}
+
+func (s *arenaStore) Set(key string, value ByteView) {
+	// The arena does not report the values it replaces, so the old one is copied out before it is overwritten.
+	var old, oldMeta []byte
+	var replacing bool
+	if s.onEvict != nil {
+		old, oldMeta, replacing = s.c.Get(key)
+	}
+	s.setting, s.evicted = key, false
+	s.c.Set(key, value.data(), s.meta(value))
+	s.setting = ""
+	if replacing && !s.evicted {
+		s.onEvict(key, s.view(old, oldMeta), EvictReplaced)
+	}
+}
+
+func (s *arenaStore) Remove(key string) {
+	s.removing = true
+	s.c.Remove(key)
+	s.removing = false
+}
+
+func (s *arenaStore) overhead() int64 {
+	return arena.EntryOverhead()
+}
+
+func (s *arenaStore) evictNext() bool {
+	return s.c.EvictOldest()
+}
+
+func (s *arenaStore) Len() int {
+	return s.c.Len()
+}
+
+func (s *arenaStore) Size() int64 {
+	return s.c.Size()
+}
+
+func (s *arenaStore) Resize(capacity int64) {
+	s.c.Resize(capacity)
+}
+
+func (s *arenaStore) Backward() iter.Seq2[string, ByteView] {
+	return func(yield func(string, ByteView) bool) {
+		for k, e := range s.c.Backward() {
+			if !yield(k, s.view(e.Value, e.Meta)) {
+				return
+			}
+		}
+	}
+}
+
+// meta returns the meta of a value in the arena: the index + 1 of its compressor (0 means none),
+// followed by the metadata of the value if it has any.
+func (s *arenaStore) meta(value ByteView) []byte {
+	if value.c == nil && value.meta == nil {
+		return nil
+	}
+	var i int
+	if value.c != nil {
+		i = slices.IndexFunc(s.compressors, func(c Compressor) bool { return c.Name() == value.c.Name() })
+		if i < 0 {
+			i = len(s.compressors)
+			s.compressors = append(s.compressors, value.c)
+		}
+		i++
+	}
+	meta := []byte{byte(i)}
+	if value.meta != nil {
+		meta = appendMeta(meta, value.meta)
+	}
+	return meta
+}
+
+// view returns the ByteView of a value in the arena with its meta.
+func (s *arenaStore) view(value, meta []byte) ByteView {
+	v := ByteView{bytes: value}
+	if len(meta) == 0 {
+		return v
+	}
+	if meta[0] > 0 {
+		v.c = s.compressors[meta[0]-1]
+	}
+	if len(meta) > 1 {
+		v.meta = parseMeta(meta[1:])
+	}
+	return v
+}
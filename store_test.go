@@ -0,0 +1,404 @@
+package gocache
+
+import (
+	"compress/gzip"
+	"fmt"
+	"iter"
+	"math/rand/v2"
+	"reflect"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fifoStore is a Store evicting the first inserted entries.
+type fifoStore struct {
+	capacity int64
+	size     int64
+	keys     []string
+	values   map[string]ByteView
+	onEvict  func(string, ByteView, EvictReason)
+}
+
+func newFIFOStore(capacity int64, onEvict func(string, ByteView, EvictReason)) Store {
+	return &fifoStore{capacity: capacity, values: make(map[string]ByteView), onEvict: onEvict}
+}
+
+func (s *fifoStore) Get(key string) (ByteView, bool) {
+	v, ok := s.values[key]
+	return v, ok
+}
+
+func (s *fifoStore) Set(key string, value ByteView) {
+	s.remove(key, EvictReplaced)
+	s.keys = append(s.keys, key)
+	s.values[key] = value
+	s.size += int64(len(key) + value.Len())
+	s.Resize(s.capacity)
+}
+
+func (s *fifoStore) Remove(key string) {
+	s.remove(key, EvictRemoved)
+}
+
+func (s *fifoStore) remove(key string, reason EvictReason) {
+	if v, ok := s.values[key]; ok {
+		s.keys = slices.DeleteFunc(s.keys, func(k string) bool { return k == key })
+		delete(s.values, key)
+		s.size -= int64(len(key) + v.Len())
+		if s.onEvict != nil {
+			s.onEvict(key, v, reason)
+		}
+	}
+}
+
+func (s *fifoStore) Len() int    { return len(s.keys) }
+func (s *fifoStore) Size() int64 { return s.size }
+
+func (s *fifoStore) Resize(capacity int64) {
+	s.capacity = capacity
+	for s.capacity > 0 && s.size > s.capacity {
+		s.remove(s.keys[0], EvictCapacity)
+	}
+}
+
+func (s *fifoStore) Backward() iter.Seq2[string, ByteView] {
+	return func(yield func(string, ByteView) bool) {
+		for _, k := range s.keys {
+			if !yield(k, s.values[k]) {
+				return
+			}
+		}
+	}
+}
+
+func TestWithStore(t *testing.T) {
+	g := NewGroup("fifo", int64(len("k1"+"k1")*2), GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte(key), nil
+		}), WithStore(newFIFOStore))
+
+	g.Get("k1")
+	g.Get("k2")
+	g.Get("k1")
+	g.Get("k3") // evicts k1 despite being recently used
+	keys := []string{}
+	for _, it := range g.mainCache.items() {
+		keys = append(keys, it.key)
+	}
+	if expected := []string{"k2", "k3"}; !reflect.DeepEqual(expected, keys) {
+		t.Fatalf("group failed to use its store (expected: %v, got: %v)", expected, keys)
+	}
+}
+
+func TestLRU(t *testing.T) {
+	evicted := []string{}
+	s := LRU(int64(len("k1"+"v1")*2), func(key string, value ByteView, reason EvictReason) {
+		evicted = append(evicted, key+":"+reason.String())
+	})
+	s.Set("k1", ByteView{bytes: []byte("v1")})
+	s.Set("k2", ByteView{bytes: []byte("v2")})
+	s.Get("k1")
+	s.Set("k3", ByteView{bytes: []byte("v3")})
+	if v, ok := s.Get("k1"); !ok || v.String() != "v1" || s.Len() != 2 {
+		t.Fatalf("LRU store hit k1=v1 failed")
+	}
+	s.Set("k1", ByteView{bytes: []byte("v1")})
+	s.Remove("k3")
+	if expected := []string{"k2:capacity", "k1:replaced", "k3:removed"}; !reflect.DeepEqual(expected, evicted) {
+		t.Fatalf("LRU store eviction failed (expected: %v, got: %v)", expected, evicted)
+	}
+}
+
+func TestTinyLFU(t *testing.T) {
+	evicted := []string{}
+	s := TinyLFU(400, func(key string, value ByteView, reason EvictReason) {
+		evicted = append(evicted, key)
+	})
+	s.Set("hot", StringView("v"))
+	// A scan of one-off keys must not flush the key accessed every 100 of them,
+	// which an LRU store of about 40 entries would evict.
+	for i := range 1000 {
+		s.Set(fmt.Sprintf("scan%04d", i), StringView("v"))
+		if i%100 == 0 {
+			s.Get("hot")
+		}
+	}
+	if _, ok := s.Get("hot"); !ok || slices.Contains(evicted, "hot") {
+		t.Fatalf("TinyLFU store evicted the frequent key in a scan")
+	}
+	if s.Size() > 400 || len(evicted) != 1001-s.Len() {
+		t.Fatalf("TinyLFU store capacity exceeded (size: %d, evicted: %d)", s.Size(), len(evicted))
+	}
+}
+
+func TestARC(t *testing.T) {
+	// Every entry is 3 bytes, e.g. key=f1 and value=v, in a store of 4 entries.
+	evicted := []string{}
+	s := ARC(12, func(key string, value ByteView, reason EvictReason) {
+		evicted = append(evicted, key)
+	})
+	for _, k := range []string{"f1", "f2"} {
+		s.Set(k, StringView("v"))
+		s.Get(k) // moves to the frequency list
+	}
+	s.Set("r1", StringView("v"))
+	s.Set("r2", StringView("v"))
+	s.Set("r3", StringView("v")) // evicts r1 from the recency list
+	s.Set("r1", StringView("v")) // a ghost hit grows the target of the recency list, and evicts r2
+	s.Set("r2", StringView("v")) // another ghost hit
+	// The recency list is now within its grown target, so the frequency list gives up f1 instead of r3.
+	if expected := []string{"r1", "r2", "f1"}; !reflect.DeepEqual(expected, evicted) {
+		t.Fatalf("ARC store failed to adapt to ghost hits (expected: %v, got: %v)", expected, evicted)
+	}
+	if _, ok := s.Get("r3"); !ok {
+		t.Fatalf("ARC store evicted the recent key r3")
+	}
+}
+
+func TestTwoQ(t *testing.T) {
+	// Every entry is 3 bytes, e.g. key=m1 and value=v, in a store of 4 entries.
+	evicted := []string{}
+	s := TwoQ(12, func(key string, value ByteView, reason EvictReason) {
+		evicted = append(evicted, key)
+	})
+	s.Set("m1", StringView("v"))
+	for i := range 4 {
+		s.Set(fmt.Sprintf("k%d", i), StringView("v")) // evicts m1 from a1in
+	}
+	s.Set("m1", StringView("v")) // m1 is set again while its ghost is in a1out, so it enters am
+	evicted = evicted[:0]
+	for i := range 10 {
+		s.Set(fmt.Sprintf("s%d", i), StringView("v"))
+	}
+	// The entries of a1in are evicted before the one of am.
+	if _, ok := s.Get("m1"); !ok || slices.Contains(evicted, "m1") {
+		t.Fatalf("2Q store evicted the am entry before the a1in ones (evicted: %v)", evicted)
+	}
+	if s.Size() > 12 {
+		t.Fatalf("2Q store capacity exceeded (size: %d)", s.Size())
+	}
+}
+
+func TestGDSF(t *testing.T) {
+	// Every entry is 4 + 4 bytes, e.g. key=k001 and value=k001, in a single shard of 8 entries.
+	c := &cache{capacity: 8 * 8, nshards: 1, newStore: GDSF}
+	c.set("slow", ByteView{bytes: []byte("slow"), cost: 10 * time.Millisecond})
+	for i := range 100 {
+		key := fmt.Sprintf("k%03d", i)
+		c.set(key, ByteView{bytes: []byte(key), cost: time.Microsecond})
+	}
+	if _, ok := c.get("slow"); !ok {
+		t.Fatalf("cache evicted the slow key with GDSF")
+	}
+	if _, ok := c.get("k000"); ok {
+		t.Fatalf("cache failed to evict the fast key k000 with GDSF")
+	}
+	if _, ok := c.get("k099"); !ok || c.len() != 8 {
+		t.Fatalf("cache failed to keep the recent fast keys with GDSF (len: %v)", c.len())
+	}
+}
+
+func TestGDSFUnknownCost(t *testing.T) {
+	// Every entry is 4 + 4 bytes, e.g. key=k001 and value=k001, in a single shard of 8 entries.
+	c := &cache{capacity: 8 * 8, nshards: 1, newStore: GDSF}
+	for _, key := range []string{"slo1", "slo2", "slo3"} {
+		c.set(key, ByteView{bytes: []byte(key), cost: 10 * time.Millisecond})
+	}
+	c.set("disk", ByteView{bytes: []byte("disk")}) // e.g. promoted from disk, without a load latency
+	for i := range 100 {
+		key := fmt.Sprintf("k%03d", i)
+		c.set(key, ByteView{bytes: []byte(key), cost: time.Microsecond})
+	}
+	if _, ok := c.get("disk"); !ok {
+		t.Fatalf("cache evicted first the key of unknown cost with GDSF")
+	}
+}
+
+func TestCLOCK(t *testing.T) {
+	// Every entry is 3 bytes, e.g. key=k1 and value=v, in a store of 3 entries.
+	evicted := []string{}
+	s := CLOCK(9, func(key string, value ByteView, reason EvictReason) {
+		evicted = append(evicted, key)
+	}).(SharedStore)
+	s.Set("k1", StringView("v"))
+	s.Set("k2", StringView("v"))
+	s.Set("k3", StringView("v"))
+	s.GetShared("k1") // gives k1 a second chance
+	s.Set("k4", StringView("v"))
+	s.Set("k5", StringView("v"))
+	if expected := []string{"k2", "k3"}; !reflect.DeepEqual(expected, evicted) {
+		t.Fatalf("CLOCK store failed to give the hit key a second chance (expected: %v, got: %v)", expected, evicted)
+	}
+}
+
+func TestS3FIFO(t *testing.T) {
+	// Every entry is 3 bytes, e.g. key=k0 and value=v, in a store of 10 entries.
+	evicted := []string{}
+	s := S3FIFO(30, func(key string, value ByteView, reason EvictReason) {
+		evicted = append(evicted, key)
+	}).(SharedStore)
+	for i := range 10 {
+		s.Set(fmt.Sprintf("k%d", i), StringView("v"))
+	}
+	s.GetShared("k0") // moves k0 to the main FIFO when it leaves the small one
+	for i := range 100 {
+		s.Set(fmt.Sprintf("s%d", i), StringView("v"))
+	}
+	// The one-off keys are evicted quickly, and the hit key is kept.
+	if _, ok := s.Get("k0"); !ok || slices.Contains(evicted, "k0") {
+		t.Fatalf("S3FIFO store evicted the hit key in a scan")
+	}
+	if !slices.Contains(evicted, "k1") || s.Size() > 30 {
+		t.Fatalf("S3FIFO store failed to evict the one-off key k1 (size: %d)", s.Size())
+	}
+}
+
+func TestSharedStores(t *testing.T) {
+	for name, newStore := range map[string]NewStoreFunc{"CLOCK": CLOCK, "S3FIFO": S3FIFO} {
+		g := NewGroup("shared-"+name, 1<<10, GetterFunc(
+			func(key string) ([]byte, error) {
+				return []byte(key), nil
+			}), WithStore(newStore), WithShards(4))
+		var wg sync.WaitGroup
+		for range 8 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := range 100 {
+					key := strconv.Itoa(i % 10)
+					if v, err := g.Get(key); err != nil || v.String() != key {
+						t.Errorf("cache Get failed with %v", name)
+					}
+				}
+			}()
+		}
+		wg.Wait()
+		if _, ok := g.mainCache.shard("key").store.(SharedStore); !ok {
+			t.Fatalf("group failed to use %v as a SharedStore", name)
+		}
+	}
+}
+
+func TestStoresRejectLarge(t *testing.T) {
+	// An entry larger than the capacity neither evicts the other entries nor stays in the store.
+	stores := map[string]NewStoreFunc{
+		"LRU": LRU, "Arena": Arena, "TinyLFU": TinyLFU, "ARC": ARC, "TwoQ": TwoQ,
+		"CLOCK": CLOCK, "S3FIFO": S3FIFO,
+	}
+	for name, newStore := range stores {
+		evicted := []string{}
+		s := newStore(40, func(key string, value ByteView, reason EvictReason) {
+			evicted = append(evicted, key)
+		})
+		s.Set("k1", StringView("v1"))
+		s.Set("k2", StringView("v2"))
+		s.Set("large", ByteView{bytes: make([]byte, 64)})
+		if _, ok := s.Get("large"); ok || !holds(s, "k1") || !holds(s, "k2") || len(evicted) != 0 {
+			t.Fatalf("%v store admitted an entry larger than its capacity (evicted: %v)", name, evicted)
+		}
+		if holds(s, "large") {
+			t.Fatalf("%v store holds a rejected entry", name)
+		}
+		s.Set("k1", ByteView{bytes: make([]byte, 64)})
+		if _, ok := s.Get("k1"); ok || s.Len() != 1 || s.Size() != int64(len("k2"+"v2")) {
+			t.Fatalf("%v store kept the previous value of a rejected entry (len: %v)", name, s.Len())
+		}
+	}
+}
+
+// BenchmarkStores compares the hit ratio and the throughput of the stores on a Zipf workload,
+// e.g. go test -bench Stores -cpu 1,4,16.
+// Each miss sets the key, and the cache holds 1/10 of the keys.
+func BenchmarkStores(b *testing.B) {
+	const nkeys = 1 << 16
+	keys := make([]string, nkeys)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("%08d", i)
+	}
+	value := ByteView{bytes: make([]byte, 56)}
+	capacity := int64(nkeys/10) * int64(len(keys[0])+value.Len())
+	for _, store := range []struct {
+		name     string
+		newStore NewStoreFunc
+	}{{"LRU", LRU}, {"CLOCK", CLOCK}, {"S3FIFO", S3FIFO}, {"TinyLFU", TinyLFU}, {"ARC", ARC}, {"TwoQ", TwoQ}, {"GDSF", GDSF}} {
+		b.Run(store.name, func(b *testing.B) {
+			c := &cache{capacity: capacity, nshards: 16, newStore: store.newStore}
+			var hits, gets atomic.Int64
+			var seed atomic.Uint64
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				zipf := rand.NewZipf(rand.New(rand.NewPCG(seed.Add(1), 0)), 1.01, 1, nkeys-1)
+				var h, n int64
+				for pb.Next() {
+					key := keys[zipf.Uint64()]
+					if _, ok := c.get(key); ok {
+						h++
+					} else {
+						c.set(key, value)
+					}
+					n++
+				}
+				hits.Add(h)
+				gets.Add(n)
+			})
+			b.ReportMetric(float64(hits.Load())/float64(gets.Load()), "hit-ratio")
+		})
+	}
+}
+
+func TestArena(t *testing.T) {
+	evicted := []string{}
+	g := NewGroup("arena", 1<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte(strings.Repeat(key, 100)), nil
+		}), WithStore(Arena), WithCompressor(Gzip(gzip.BestSpeed)), WithEvictHook(
+		func(key string, value ByteView, reason EvictReason) {
+			evicted = append(evicted, key+":"+reason.String())
+		}))
+	defer g.Close()
+
+	if v, err := g.Get("key"); err != nil || v.String() != strings.Repeat("key", 100) {
+		t.Fatalf("cache Get failed with Arena")
+	}
+	if v, ok := g.mainCache.get("key"); !ok || v.c == nil {
+		t.Fatalf("Arena store failed to keep the compressor of the value")
+	}
+	if v, err := g.Get("key"); err != nil || v.String() != strings.Repeat("key", 100) || g.Stats().Hits != 1 {
+		t.Fatalf("cache hit failed with Arena")
+	}
+	g.mainCache.remove("key", EvictRemoved)
+	if expected := []string{"key:removed"}; !reflect.DeepEqual(expected, evicted) {
+		t.Fatalf("Arena store eviction failed (expected: %v, got: %v)", expected, evicted)
+	}
+	if _, ok := g.mainCache.shard("key").store.(SharedStore); !ok {
+		t.Fatalf("group failed to use Arena as a SharedStore")
+	}
+}
+
+// BenchmarkStoreGC measures the duration of a garbage collection with a million entries in the main cache,
+// e.g. go test -bench StoreGC.
+func BenchmarkStoreGC(b *testing.B) {
+	const nkeys = 1 << 20
+	for _, store := range []struct {
+		name     string
+		newStore NewStoreFunc
+	}{{"LRU", LRU}, {"Arena", Arena}} {
+		b.Run(store.name, func(b *testing.B) {
+			c := &cache{newStore: store.newStore}
+			for i := range nkeys {
+				c.set(strconv.Itoa(i), ByteView{bytes: make([]byte, 32)})
+			}
+			b.ResetTimer()
+			for range b.N {
+				runtime.GC()
+			}
+			runtime.KeepAlive(c)
+		})
+	}
+}
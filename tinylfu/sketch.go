@@ -0,0 +1,129 @@
+package tinylfu
+
+import (
+	"hash/maphash"
+	"math/bits"
+)
+
+const (
+	sketchDepth      = 4  // the number of rows of the count-min sketch
+	maxCount         = 15 // counters saturate like 4-bit counters
+	minSketchWidth   = 64
+	samplesPerWidth  = 10 // the counters are halved after width * samplesPerWidth increments
+	doorkeeperHashes = 2
+)
+
+// A sketch estimates the access frequency of keys with a count-min sketch fronted by a doorkeeper.
+// The first access to a key only sets the doorkeeper, so that one-hit wonders do not pollute the sketch.
+// The counters are periodically halved and the doorkeeper cleared, so that old popularity fades away.
+type sketch struct {
+	seed       maphash.Seed
+	width      uint64 // the number of counters per row; a power of 2
+	counters   []uint8
+	doorkeeper []uint64 // a bloom filter of width * 8 bits
+	additions  uint64   // the increments since the last reset
+}
+
+func newSketch(width int) *sketch {
+	s := &sketch{seed: maphash.MakeSeed()}
+	s.resize(width)
+	return s
+}
+
+// resize sets the width of the sketch to at least width, keeping the counts.
+// As the widths are powers of 2, the counters of a hash in a wider sketch are copies of the ones in the narrower one,
+// and the counters of a narrower sketch keep the largest of the ones they fold.
+func (s *sketch) resize(width int) {
+	oldWidth, counters, doorkeeper := s.width, s.counters, s.doorkeeper
+	s.width = max(uint64(1)<<bits.Len64(uint64(max(width, minSketchWidth)-1)), minSketchWidth)
+	s.counters = make([]uint8, sketchDepth*s.width)
+	s.doorkeeper = make([]uint64, s.width/8)
+	if counters == nil {
+		return
+	}
+	for i := range uint64(sketchDepth) {
+		for j := range max(s.width, oldWidth) {
+			idx := i*s.width + j&(s.width-1)
+			s.counters[idx] = max(s.counters[idx], counters[i*oldWidth+j&(oldWidth-1)])
+		}
+	}
+	for j := range max(len(s.doorkeeper), len(doorkeeper)) {
+		s.doorkeeper[j%len(s.doorkeeper)] |= doorkeeper[j%len(doorkeeper)]
+	}
+}
+
+// increment records an access to key.
+func (s *sketch) increment(key string) {
+	h := maphash.String(s.seed, key)
+	if !s.admit(h) {
+		return
+	}
+	for i := range uint64(sketchDepth) {
+		idx := s.index(h, i)
+		if s.counters[idx] < maxCount {
+			s.counters[idx]++
+		}
+	}
+	s.additions++
+	if s.additions >= s.width*samplesPerWidth {
+		s.age()
+	}
+}
+
+// estimate returns the estimated access frequency of key.
+func (s *sketch) estimate(key string) int {
+	h := maphash.String(s.seed, key)
+	count := uint8(maxCount)
+	for i := range uint64(sketchDepth) {
+		count = min(count, s.counters[s.index(h, i)])
+	}
+	if s.contains(h) {
+		return int(count) + 1
+	}
+	return int(count)
+}
+
+// age halves the counters and clears the doorkeeper.
+func (s *sketch) age() {
+	for i := range s.counters {
+		s.counters[i] /= 2
+	}
+	clear(s.doorkeeper)
+	s.additions = 0
+}
+
+// index returns the index of the counter of hash h in row i.
+func (s *sketch) index(h uint64, i uint64) uint64 {
+	return i*s.width + mix(h, i)&(s.width-1)
+}
+
+// mix derives the i-th hash from hash h.
+func mix(h uint64, i uint64) uint64 {
+	h = (h + i*0x9e3779b97f4a7c15) * 0xbf58476d1ce4e5b9
+	return h ^ h>>31
+}
+
+// admit adds hash h to the doorkeeper and reports whether it was already there.
+func (s *sketch) admit(h uint64) bool {
+	if s.contains(h) {
+		return true
+	}
+	nbits := uint64(len(s.doorkeeper)) * 64
+	for i := range uint64(doorkeeperHashes) {
+		bit := mix(h, sketchDepth+i) % nbits
+		s.doorkeeper[bit/64] |= 1 << (bit % 64)
+	}
+	return false
+}
+
+// contains reports whether hash h is in the doorkeeper.
+func (s *sketch) contains(h uint64) bool {
+	nbits := uint64(len(s.doorkeeper)) * 64
+	for i := range uint64(doorkeeperHashes) {
+		bit := mix(h, sketchDepth+i) % nbits
+		if s.doorkeeper[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,55 @@
+package tinylfu
+
+import (
+	"testing"
+)
+
+func TestSketch(t *testing.T) {
+	s := newSketch(minSketchWidth)
+	for range 5 {
+		s.increment("hot")
+	}
+	s.increment("cold")
+	if hot, cold := s.estimate("hot"), s.estimate("cold"); hot != 5 || cold != 1 {
+		t.Fatalf("sketch estimate failed (expected: 5 and 1, got: %d and %d)", hot, cold)
+	}
+	if n := s.estimate("never"); n != 0 {
+		t.Fatalf("sketch estimate of an unseen key failed (got: %d)", n)
+	}
+}
+
+func TestSketchAging(t *testing.T) {
+	s := newSketch(minSketchWidth)
+	for range 8 {
+		s.increment("hot")
+	}
+	s.age()
+	if n := s.estimate("hot"); n != 3 {
+		t.Fatalf("sketch failed to age (expected: 3, got: %d)", n)
+	}
+
+	// Aging is triggered by the number of increments past the doorkeeper.
+	s = newSketch(minSketchWidth)
+	for range s.width*samplesPerWidth + 1 {
+		s.increment("hot")
+	}
+	if n := s.estimate("hot"); s.additions != 0 || n != maxCount/2 {
+		t.Fatalf("sketch failed to age after %d increments (expected: %d, got: %d)", s.width*samplesPerWidth, maxCount/2, n)
+	}
+}
+
+func TestSketchResize(t *testing.T) {
+	s := newSketch(minSketchWidth)
+	for range 5 {
+		s.increment("hot")
+	}
+	s.increment("cold")
+
+	// The counts survive a change of the width in both directions.
+	for _, width := range []int{4 * minSketchWidth, minSketchWidth} {
+		s.resize(width)
+		if hot, cold := s.estimate("hot"), s.estimate("cold"); hot != 5 || cold != 1 {
+			t.Fatalf("sketch resize to %d lost the counts (expected: 5 and 1, got: %d and %d)", width, hot, cold)
+		}
+	}
+}
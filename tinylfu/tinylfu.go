@@ -0,0 +1,237 @@
+package tinylfu
+
+import (
+	"container/list"
+	"iter"
+)
+
+const (
+	windowRatio    = 0.01 // the part of the capacity for the window LRU
+	protectedRatio = 0.8  // the part of the main SLRU for its protected segment
+)
+
+// The segments an entry can be in.
+const (
+	window = iota
+	probation
+	protected
+)
+
+// A W-TinyLFU cache.
+//
+// New entries go to a small window LRU. Entries leaving the window are candidates for the main
+// segmented LRU, and are only admitted if they were accessed more often than the entry that would be evicted
+// in their place, as estimated by a count-min sketch. Entries of the main SLRU start in its probation segment
+// and are promoted to its protected segment when accessed again.
+type Cache struct {
+	capacity int64                         // the maximum size of the cache; capacity <= 0 means no limit
+	size     int64                         // the current size of the cache
+	segments [3]segment                    // the window, probation and protected segments
+	cache    map[string]*list.Element      // the key to element mapping
+	sketch   *sketch                       // the access frequency estimation
+	onEvict  func(key string, value Value) // (optional) callback when an entry is evicted
+}
+
+// A segment is an LRU list of entries.
+type segment struct {
+	ll   *list.List
+	size int64
+}
+
+// The element in the linked lists. The KV pair of the cache.
+type entry struct {
+	key     string
+	value   Value
+	segment int
+}
+
+// A Value in the cache implements the Len method to return its size in bytes.
+type Value interface {
+	Len() int // the size in bytes
+}
+
+// The constructor of Cache.
+func New(capacity int64, onEvict func(string, Value)) *Cache {
+	c := &Cache{
+		capacity: capacity,
+		cache:    make(map[string]*list.Element),
+		sketch:   newSketch(minSketchWidth),
+		onEvict:  onEvict,
+	}
+	for i := range c.segments {
+		c.segments[i].ll = list.New()
+	}
+	return c
+}
+
+// Get gets the value from the cache by key.
+func (c *Cache) Get(key string) (Value, bool) {
+	ele, ok := c.cache[key]
+	if !ok {
+		return nil, false
+	}
+	c.sketch.increment(key)
+	c.touch(ele)
+	c.maintain()
+	return ele.Value.(*entry).value, true
+}
+
+// Peek gets the value of key from the cache without updating its recency or frequency.
+func (c *Cache) Peek(key string) (Value, bool) {
+	ele, ok := c.cache[key]
+	if !ok {
+		return nil, false
+	}
+	return ele.Value.(*entry).value, true
+}
+
+// Set sets a value with a key in the cache.
+//
+// An entry larger than the capacity is rejected rather than evicting the whole cache,
+// and the previous value of key, if any, is removed.
+func (c *Cache) Set(key string, value Value) {
+	if c.capacity > 0 && int64(len(key))+int64(value.Len()) > c.capacity {
+		c.Remove(key)
+		return
+	}
+	if ele, ok := c.cache[key]; ok {
+		kv := ele.Value.(*entry)
+		delta := int64(value.Len()) - int64(kv.value.Len())
+		c.segments[kv.segment].size += delta
+		c.size += delta
+		kv.value = value
+		c.touch(ele)
+	} else {
+		c.sketch.increment(key)
+		kv := &entry{key: key, value: value, segment: window}
+		c.cache[key] = c.segments[window].ll.PushFront(kv)
+		c.segments[window].size += size(kv)
+		c.size += size(kv)
+		if len(c.cache) > int(c.sketch.width) {
+			c.sketch.resize(2 * len(c.cache))
+		}
+	}
+	c.maintain()
+}
+
+// Remove removes the entry of key from the cache.
+// The onEvict callback is called for the removed entry.
+func (c *Cache) Remove(key string) {
+	if ele, ok := c.cache[key]; ok {
+		c.removeElement(ele)
+	}
+}
+
+// Len returns the number of cache entries.
+func (c *Cache) Len() int {
+	return len(c.cache)
+}
+
+// Size returns the size of the cache entries in bytes.
+func (c *Cache) Size() int64 {
+	return c.size
+}
+
+// Resize changes the capacity of the cache, evicting entries if it shrinks.
+func (c *Cache) Resize(capacity int64) {
+	c.capacity = capacity
+	c.maintain()
+}
+
+// Backward returns an iterator over the cache entries from the first to the last to be evicted:
+// the probation, protected and window segments, each from the least to the most recently used.
+func (c *Cache) Backward() iter.Seq2[string, Value] {
+	return func(yield func(string, Value) bool) {
+		for _, seg := range []int{probation, protected, window} {
+			for ele := c.segments[seg].ll.Back(); ele != nil; ele = ele.Prev() {
+				kv := ele.Value.(*entry)
+				if !yield(kv.key, kv.value) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// touch updates the recency of an accessed entry, promoting it from probation to protected.
+func (c *Cache) touch(ele *list.Element) {
+	kv := ele.Value.(*entry)
+	if kv.segment == probation {
+		c.move(ele, protected)
+		return
+	}
+	c.segments[kv.segment].ll.MoveToFront(ele)
+}
+
+// maintain moves entries between the segments and evicts entries to respect the capacities.
+func (c *Cache) maintain() {
+	if c.capacity <= 0 {
+		return
+	}
+	windowCapacity := max(int64(float64(c.capacity)*windowRatio), 1)
+	protectedCapacity := int64(float64(c.capacity-windowCapacity) * protectedRatio)
+
+	// Demote the overflow of the protected segment to probation.
+	for c.segments[protected].size > protectedCapacity {
+		c.move(c.segments[protected].ll.Back(), probation)
+	}
+	// Entries leaving the window become candidates at the front of probation.
+	var candidates []*list.Element
+	for c.segments[window].size > windowCapacity {
+		candidates = append(candidates, c.move(c.segments[window].ll.Back(), probation))
+	}
+	// Each candidate competes with the victim of probation, and the less frequently accessed one is evicted.
+	for c.size > c.capacity {
+		victim := c.victim()
+		if len(candidates) == 0 || victim == candidates[0] {
+			c.removeElement(victim)
+			candidates = candidates[min(1, len(candidates)):]
+			continue
+		}
+		candidate := candidates[0]
+		if c.sketch.estimate(candidate.Value.(*entry).key) > c.sketch.estimate(victim.Value.(*entry).key) {
+			c.removeElement(victim)
+		} else {
+			c.removeElement(candidate)
+			candidates = candidates[1:]
+		}
+	}
+}
+
+// victim returns the entry to be evicted first.
+func (c *Cache) victim() *list.Element {
+	for _, seg := range []int{probation, protected, window} {
+		if ele := c.segments[seg].ll.Back(); ele != nil {
+			return ele
+		}
+	}
+	return nil
+}
+
+// move moves an entry to the front of another segment and returns its new element.
+func (c *Cache) move(ele *list.Element, seg int) *list.Element {
+	kv := ele.Value.(*entry)
+	c.segments[kv.segment].ll.Remove(ele)
+	c.segments[kv.segment].size -= size(kv)
+	kv.segment = seg
+	ele = c.segments[seg].ll.PushFront(kv)
+	c.cache[kv.key] = ele
+	c.segments[seg].size += size(kv)
+	return ele
+}
+
+func (c *Cache) removeElement(ele *list.Element) {
+	kv := ele.Value.(*entry)
+	c.segments[kv.segment].ll.Remove(ele)
+	c.segments[kv.segment].size -= size(kv)
+	delete(c.cache, kv.key)
+	c.size -= size(kv)
+	if c.onEvict != nil {
+		c.onEvict(kv.key, kv.value)
+	}
+}
+
+// size returns the size of an entry in bytes.
+func size(kv *entry) int64 {
+	return int64(len(kv.key)) + int64(kv.value.Len())
+}
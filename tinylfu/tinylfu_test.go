@@ -0,0 +1,92 @@
+package tinylfu
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+type value string
+
+func (v value) Len() int {
+	return len(v)
+}
+
+func TestGet(t *testing.T) {
+	c := New(int64(0), nil)
+	c.Set("k1", value("v1"))
+	if v, ok := c.Get("k1"); !ok || string(v.(value)) != "v1" {
+		t.Fatalf("cache hit k1=v1 failed")
+	}
+	if _, ok := c.Get("k2"); ok {
+		t.Fatalf("cache miss k2 failed")
+	}
+}
+
+func TestSet(t *testing.T) {
+	c := New(int64(0), nil)
+	c.Set("k1", value("v1"))
+	c.Set("k1", value("value1"))
+	if c.Size() != int64(len("k1"+"value1")) || c.Len() != 1 {
+		t.Fatalf("cache set failed (expected: %v, got: %v)", len("k1"+"value1"), c.Size())
+	}
+}
+
+func TestCapacity(t *testing.T) {
+	keys := []string{}
+	c := New(int64(100), func(key string, value Value) {
+		keys = append(keys, key)
+	})
+	for i := range 100 {
+		c.Set(fmt.Sprintf("k%02d", i), value("v"))
+	}
+	if c.Size() > 100 || len(keys) != 100-c.Len() {
+		t.Fatalf("cache capacity exceeded (size: %d, evicted: %d)", c.Size(), len(keys))
+	}
+	c.Remove("k99")
+	if _, ok := c.Get("k99"); ok || keys[len(keys)-1] != "k99" {
+		t.Fatalf("cache remove k99 failed")
+	}
+}
+
+func TestScanResistance(t *testing.T) {
+	c := New(int64(400), nil)
+	hot := []string{}
+	for i := range 50 {
+		hot = append(hot, fmt.Sprintf("hot%02d", i))
+	}
+	// Make the hot keys frequently accessed.
+	for range 5 {
+		for _, k := range hot {
+			if _, ok := c.Get(k); !ok {
+				c.Set(k, value("v"))
+			}
+		}
+	}
+	// A scan of one-off keys must not flush the hot keys.
+	for i := range 1000 {
+		c.Set(fmt.Sprintf("scan%04d", i), value("v"))
+	}
+	hits := 0
+	for _, k := range hot {
+		if _, ok := c.Get(k); ok {
+			hits++
+		}
+	}
+	if hits < len(hot)*9/10 {
+		t.Fatalf("cache failed to resist the scan (hot keys kept: %d/%d)", hits, len(hot))
+	}
+}
+
+func TestBackward(t *testing.T) {
+	c := New(int64(0), nil)
+	c.Set("k1", value("v1"))
+	c.Set("k2", value("v2"))
+	keys := []string{}
+	for k := range c.Backward() {
+		keys = append(keys, k)
+	}
+	if expected := []string{"k1", "k2"}; !reflect.DeepEqual(expected, keys) {
+		t.Fatalf("cache backward iteration failed (expected: %v, got: %v)", expected, keys)
+	}
+}
@@ -0,0 +1,210 @@
+package twoq
+
+import (
+	"container/list"
+	"iter"
+)
+
+const (
+	inRatio  = 0.25 // the part of the capacity for the a1in FIFO
+	outRatio = 0.5  // the part of the capacity, in sizes of the original entries, for the a1out ghosts
+)
+
+// The queues an entry can be in.
+const (
+	a1in  = iota // resident entries seen once, in FIFO order
+	am           // resident entries seen again after leaving a1in, in LRU order
+	a1out        // ghosts of entries evicted from a1in, in FIFO order
+)
+
+// A 2Q cache with byte capacity.
+//
+// New entries go to the a1in FIFO, and hits there do not change their order. Entries evicted from a1in
+// are remembered as ghosts in a1out, and only an entry set again while its ghost is there enters the
+// main LRU am. One-off keys thus never reach am, which makes the cache resist scans.
+type Cache struct {
+	capacity int64                         // the maximum size of the cache; capacity <= 0 means no limit
+	queues   [3]queue                      // a1in, am and a1out
+	cache    map[string]*list.Element      // the key to element mapping, including ghosts
+	onEvict  func(key string, value Value) // (optional) callback when an entry is evicted
+}
+
+// A queue is a list of entries with their total size.
+type queue struct {
+	ll   *list.List
+	size int64
+}
+
+// The element in the linked lists. The KV pair of the cache, or the key and size of a ghost.
+type entry struct {
+	key   string
+	value Value // nil for ghosts
+	size  int64
+	queue int
+}
+
+// A Value in the cache implements the Len method to return its size in bytes.
+type Value interface {
+	Len() int // the size in bytes
+}
+
+// The constructor of Cache.
+func New(capacity int64, onEvict func(string, Value)) *Cache {
+	c := &Cache{
+		capacity: capacity,
+		cache:    make(map[string]*list.Element),
+		onEvict:  onEvict,
+	}
+	for i := range c.queues {
+		c.queues[i].ll = list.New()
+	}
+	return c
+}
+
+// Get gets the value from the cache by key.
+func (c *Cache) Get(key string) (Value, bool) {
+	ele, ok := c.cache[key]
+	if !ok {
+		return nil, false
+	}
+	kv := ele.Value.(*entry)
+	switch kv.queue {
+	case am:
+		c.queues[am].ll.MoveToFront(ele)
+	case a1out:
+		return nil, false
+	}
+	return kv.value, true
+}
+
+// Peek gets the value of key from the cache without updating its recency.
+func (c *Cache) Peek(key string) (Value, bool) {
+	ele, ok := c.cache[key]
+	if !ok || ele.Value.(*entry).queue == a1out {
+		return nil, false
+	}
+	return ele.Value.(*entry).value, true
+}
+
+// Set sets a value with a key in the cache.
+//
+// An entry larger than the capacity is rejected rather than evicting the whole cache,
+// and the previous value of key, if any, is removed.
+func (c *Cache) Set(key string, value Value) {
+	size := int64(len(key)) + int64(value.Len())
+	if c.capacity > 0 && size > c.capacity {
+		c.Remove(key)
+		return
+	}
+	if ele, ok := c.cache[key]; ok {
+		kv := ele.Value.(*entry)
+		switch kv.queue {
+		case a1out:
+			// Seen again after leaving a1in: admit to am.
+			c.unlink(ele)
+			kv.value, kv.size = value, size
+			c.cache[key] = c.push(kv, am)
+		case am:
+			c.queues[am].ll.MoveToFront(ele)
+			fallthrough
+		default:
+			c.queues[kv.queue].size += size - kv.size
+			kv.value, kv.size = value, size
+		}
+	} else {
+		c.cache[key] = c.push(&entry{key: key, value: value, size: size}, a1in)
+	}
+	c.reclaim()
+}
+
+// Remove removes the entry of key from the cache.
+// The onEvict callback is called for the removed entry.
+func (c *Cache) Remove(key string) {
+	ele, ok := c.cache[key]
+	if !ok || ele.Value.(*entry).queue == a1out {
+		return
+	}
+	kv := c.unlink(ele)
+	delete(c.cache, key)
+	if c.onEvict != nil {
+		c.onEvict(kv.key, kv.value)
+	}
+}
+
+// Len returns the number of cache entries.
+func (c *Cache) Len() int {
+	return c.queues[a1in].ll.Len() + c.queues[am].ll.Len()
+}
+
+// Size returns the size of the cache entries in bytes.
+func (c *Cache) Size() int64 {
+	return c.queues[a1in].size + c.queues[am].size
+}
+
+// Resize changes the capacity of the cache, evicting entries if it shrinks.
+func (c *Cache) Resize(capacity int64) {
+	c.capacity = capacity
+	c.reclaim()
+}
+
+// Backward returns an iterator over the cache entries from the first to the last to be evicted:
+// the entries of a1in from the oldest, and then of am from the least recently used.
+func (c *Cache) Backward() iter.Seq2[string, Value] {
+	return func(yield func(string, Value) bool) {
+		for _, q := range []int{a1in, am} {
+			for ele := c.queues[q].ll.Back(); ele != nil; ele = ele.Prev() {
+				kv := ele.Value.(*entry)
+				if !yield(kv.key, kv.value) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// reclaim evicts entries until the cache fits its capacity.
+// a1in gives up its oldest entries to a1out while it is over its share, and am its LRU entries otherwise.
+func (c *Cache) reclaim() {
+	if c.capacity <= 0 {
+		return
+	}
+	inCapacity := int64(float64(c.capacity) * inRatio)
+	outCapacity := int64(float64(c.capacity) * outRatio)
+	for c.Size() > c.capacity {
+		if c.queues[a1in].size > inCapacity || c.queues[am].ll.Len() == 0 {
+			ele := c.queues[a1in].ll.Back()
+			kv := c.unlink(ele)
+			value := kv.value
+			kv.value = nil
+			c.cache[kv.key] = c.push(kv, a1out)
+			if c.onEvict != nil {
+				c.onEvict(kv.key, value)
+			}
+		} else {
+			kv := c.unlink(c.queues[am].ll.Back())
+			delete(c.cache, kv.key)
+			if c.onEvict != nil {
+				c.onEvict(kv.key, kv.value)
+			}
+		}
+	}
+	for c.queues[a1out].size > outCapacity {
+		kv := c.unlink(c.queues[a1out].ll.Back())
+		delete(c.cache, kv.key)
+	}
+}
+
+// push pushes an entry to the front of a queue.
+func (c *Cache) push(kv *entry, q int) *list.Element {
+	kv.queue = q
+	c.queues[q].size += kv.size
+	return c.queues[q].ll.PushFront(kv)
+}
+
+// unlink removes an element from its queue.
+func (c *Cache) unlink(ele *list.Element) *entry {
+	kv := ele.Value.(*entry)
+	c.queues[kv.queue].ll.Remove(ele)
+	c.queues[kv.queue].size -= kv.size
+	return kv
+}
@@ -0,0 +1,68 @@
+package twoq
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+type value string
+
+func (v value) Len() int {
+	return len(v)
+}
+
+func TestGet(t *testing.T) {
+	c := New(int64(0), nil)
+	c.Set("k1", value("v1"))
+	if v, ok := c.Get("k1"); !ok || string(v.(value)) != "v1" {
+		t.Fatalf("cache hit k1=v1 failed")
+	}
+	if _, ok := c.Get("k2"); ok {
+		t.Fatalf("cache miss k2 failed")
+	}
+	c.Set("k1", value("value1"))
+	if c.Size() != int64(len("k1"+"value1")) || c.Len() != 1 {
+		t.Fatalf("cache set failed (expected: %v, got: %v)", len("k1"+"value1"), c.Size())
+	}
+}
+
+func TestAdmission(t *testing.T) {
+	keys := []string{}
+	c := New(int64(21), func(key string, value Value) {
+		keys = append(keys, key)
+	})
+	for i := range 10 {
+		c.Set(fmt.Sprintf("k%d", i), value("v"))
+	}
+	if expected := []string{"k0", "k1", "k2"}; !reflect.DeepEqual(expected, keys) {
+		t.Fatalf("cache failed to evict from a1in (expected: %v, got: %v)", expected, keys)
+	}
+	// k0 is a ghost in a1out, so setting it again admits it to am.
+	if _, ok := c.Get("k0"); ok {
+		t.Fatalf("cache hit a ghost")
+	}
+	c.Set("k0", value("v"))
+	if c.queues[am].ll.Len() != 1 {
+		t.Fatalf("cache failed to admit k0 to am")
+	}
+	// A scan of one-off keys does not evict k0 from am.
+	for i := range 100 {
+		c.Set(fmt.Sprintf("scan%d", i), value("v"))
+	}
+	if _, ok := c.Get("k0"); !ok || c.Size() > 21 {
+		t.Fatalf("cache failed to resist the scan")
+	}
+}
+
+func TestRemove(t *testing.T) {
+	keys := []string{}
+	c := New(int64(0), func(key string, value Value) {
+		keys = append(keys, key)
+	})
+	c.Set("k1", value("v1"))
+	c.Remove("k1")
+	if _, ok := c.Get("k1"); ok || c.Len() != 0 || len(keys) != 1 {
+		t.Fatalf("cache remove k1 failed")
+	}
+}